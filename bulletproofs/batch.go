@@ -0,0 +1,286 @@
+package bulletproofs
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dat-incognito-org/newbp/operation"
+)
+
+// InvalidProofsError reports which proofs of a batch failed verification
+type InvalidProofsError struct {
+	// Indices holds the positions of the invalid proofs in the batch, in increasing order
+	Indices []int
+}
+
+func (e *InvalidProofsError) Error() string {
+	return fmt.Sprintf("%d invalid range proofs at indices %v", len(e.Indices), e.Indices)
+}
+
+// VerifyBatchIdentify verifies proofs like VerifyBatch. When the batch equation fails, it bisects the batch,
+// re-running VerifyBatch on each half, until it has isolated every invalid proof. It returns nil if all proofs
+// are valid and an *InvalidProofsError listing the invalid ones otherwise; malformed or incomplete proofs are
+// reported as invalid without being batched.
+// With k invalid proofs out of n, it runs at most about 2k*log2(n) batch checks.
+func VerifyBatchIdentify(proofs []*AggregatedRangeProof) error {
+	var invalid []int
+	indices := make([]int, 0, len(proofs))
+	for i, proof := range proofs {
+		if proof == nil || proof.IsNil() {
+			invalid = append(invalid, i)
+			continue
+		}
+		if _, _, _, err := proof.dimensions(); err != nil {
+			invalid = append(invalid, i)
+			continue
+		}
+		indices = append(indices, i)
+	}
+	invalid = append(invalid, bisectBatch(proofs, indices)...)
+	if len(invalid) == 0 {
+		return nil
+	}
+	sort.Ints(invalid)
+	return &InvalidProofsError{Indices: invalid}
+}
+
+// bisectBatch returns the indices of the invalid proofs among proofs[indices]
+func bisectBatch(proofs []*AggregatedRangeProof, indices []int) []int {
+	if len(indices) == 0 {
+		return nil
+	}
+	batch := make([]*AggregatedRangeProof, len(indices))
+	for i, index := range indices {
+		batch[i] = proofs[index]
+	}
+	if valid, err, _ := VerifyBatch(batch); valid && err == nil {
+		return nil
+	}
+	if len(indices) == 1 {
+		return []int{indices[0]}
+	}
+	half := len(indices) / 2
+	return append(bisectBatch(proofs, indices[:half]), bisectBatch(proofs, indices[half:])...)
+}
+
+// batchVerifierFlushTerms bounds the number of proof-specific terms a BatchVerifier keeps before folding them into
+// its running point
+const batchVerifierFlushTerms = 1024
+
+// BatchVerifier verifies AggregatedRangeProofs as they arrive. Each added proof's two verification equations are
+// weighted by random scalars and folded into running sums: the coefficients of the shared generators g, h, u and the
+// value and blinding bases of its RangeProofParams are summed in place, and the terms over the proof's own points (commitments, A, S, T1, T2,
+// L, R and the sums per asset tag) are multiplied out every batchVerifierFlushTerms terms. Memory therefore stays bounded
+// however many proofs are added. The equations are those of VerifyBatch, so a BatchVerifier accepts exactly what
+// VerifyBatch accepts.
+// A BatchVerifier is not safe for concurrent use.
+type BatchVerifier struct {
+	params     *RangeProofParams
+	gScalars   operation.ScalarVector
+	hScalars   operation.ScalarVector
+	uScalar    *operation.Scalar
+	hBase      *operation.Scalar
+	valueBase  *operation.Scalar
+	randomBase *operation.Scalar
+	// tagScalars sums the value base coefficients of CA proofs per asset tag, since a block usually
+	// carries many proofs of the same asset
+	tagScalars map[string]*operation.Scalar
+	tags       map[string]*operation.Point
+	pending    *msMultBuilder
+	acc        *operation.Point
+	count      int
+}
+
+// NewBatchVerifier returns an empty batch of PRV proofs, over DefaultRangeProofParams
+func NewBatchVerifier() *BatchVerifier {
+	return NewBatchVerifierWithParams(DefaultRangeProofParams())
+}
+
+// NewBatchVerifierWithParams returns an empty batch of proofs made over params, such as by params.Prove.
+// AddUsingBase then takes proofs made over params.WithValueBase(anAssetTag).
+func NewBatchVerifierWithParams(params *RangeProofParams) *BatchVerifier {
+	return &BatchVerifier{
+		params:     params,
+		gScalars:   operation.NewScalarVector(len(params.g)),
+		hScalars:   operation.NewScalarVector(len(params.h)),
+		uScalar:    new(operation.Scalar).FromUint64(0),
+		hBase:      new(operation.Scalar).FromUint64(0),
+		valueBase:  new(operation.Scalar).FromUint64(0),
+		randomBase: new(operation.Scalar).FromUint64(0),
+		tagScalars: map[string]*operation.Scalar{},
+		tags:       map[string]*operation.Point{},
+		pending:    NewMSMultBuilder(true).SetWorkers(MSMWorkers),
+		acc:        new(operation.Point).Identity(),
+	}
+}
+
+// Len returns the number of proofs added so far
+func (bv *BatchVerifier) Len() int {
+	return bv.count
+}
+
+// Add folds a PRV proof into the batch. A malformed proof is rejected with an error and leaves the batch unchanged.
+func (bv *BatchVerifier) Add(proof *AggregatedRangeProof) error {
+	return bv.add(proof, nil)
+}
+
+// AddUsingBase folds a proof made by ProveUsingBase with anAssetTag into the batch
+func (bv *BatchVerifier) AddUsingBase(proof *AggregatedRangeProof, anAssetTag *operation.Point) error {
+	if anAssetTag == nil {
+		return fmt.Errorf("asset tag is nil")
+	}
+	return bv.add(proof, anAssetTag)
+}
+
+// add folds w1 times statement 1 and w2 times statement 3 minus statement 2 of verifyFaster, which cancels p:
+//
+//	w1 * (T1^x * T2^(x^2) * V^(z^(j+2)) * G^(delta - tHat) * H^(-tauX))
+//	w2 * (g^(s + z) * h^(y^(-i) hada (sInverse - z^(j+2)*2^n) - z) * u^((ab - tHat)*H(x)) * HBase^mu * L^(-v^2) * R^(-v^-2) * A^-1 * S^(-x))
+//
+// where G is anAssetTag for CA proofs and the value base of bv.params otherwise, and H and HBase are its blinding base
+func (bv *BatchVerifier) add(proof *AggregatedRangeProof, anAssetTag *operation.Point) error {
+	if proof == nil || proof.IsNil() {
+		return fmt.Errorf("range proof is incomplete")
+	}
+	maxExp, numValuePad, N, err := proof.dimensions()
+	if err != nil {
+		return err
+	}
+	if err = bv.params.checkCapacity(N); err != nil {
+		return err
+	}
+	y := generateChallenge(legacyTranscript.seed(bv.params.cs, maxExp, proof.cmsValue), []*operation.Point{proof.a, proof.s})
+	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a, proof.s})
+	x := generateChallenge(z.ToBytesS(), []*operation.Point{proof.t1, proof.t2})
+	challenges := innerProductChallenges(x.ToBytesS(), proof.innerProductProof.l, proof.innerProductProof.r)
+	inverses, err := batchInverse(append([]*operation.Scalar{y}, challenges...))
+	if err != nil {
+		return err
+	}
+	yInverse, challengeInverses := inverses[0], inverses[1:]
+	w1, w2 := operation.RandomScalar(), operation.RandomScalar()
+	minusW2 := new(operation.Scalar).Sub(operation.ScZero, w2)
+	zSquare := new(operation.Scalar).Mul(z, z)
+	xSquare := new(operation.Scalar).Mul(x, x)
+	hashX := operation.HashToScalar(x.ToBytesS())
+
+	// statement 1
+	deltaYZ := computeDeltaYZ(z, zSquare, powerVector(y, N), N, maxExp)
+	valueScalar := new(operation.Scalar).Mul(w1, new(operation.Scalar).Sub(deltaYZ, proof.tHat))
+	if anAssetTag == nil {
+		bv.valueBase.Add(bv.valueBase, valueScalar)
+	} else {
+		key := string(anAssetTag.ToBytesS())
+		if sum, ok := bv.tagScalars[key]; ok {
+			sum.Add(sum, valueScalar)
+		} else {
+			bv.tagScalars[key] = valueScalar
+			bv.tags[key] = anAssetTag
+		}
+	}
+	bv.randomBase.Sub(bv.randomBase, new(operation.Scalar).Mul(w1, proof.tauX))
+	bv.pending.Append([]*operation.Scalar{new(operation.Scalar).Mul(w1, x), new(operation.Scalar).Mul(w1, xSquare)}, []*operation.Point{proof.t1, proof.t2})
+	zExp := new(operation.Scalar).Mul(w1, zSquare)
+	for _, cm := range proof.cmsValue {
+		bv.pending.AppendSingle(new(operation.Scalar).Set(zExp), cm)
+		zExp.Mul(zExp, z)
+	}
+
+	// statements 2 and 3
+	L, R := proof.innerProductProof.l, proof.innerProductProof.r
+	s := operation.NewScalarVector(N).Fill(proof.innerProductProof.a)
+	sInverse := operation.NewScalarVector(N).Fill(proof.innerProductProof.b)
+	logN := len(L)
+	for i := range L {
+		v, vInverse := challenges[i], challengeInverses[i]
+		vSquare := new(operation.Scalar).Mul(v, v)
+		vInverseSquare := new(operation.Scalar).Mul(vInverse, vInverse)
+		bv.pending.Append([]*operation.Scalar{vSquare.Mul(vSquare, minusW2), vInverseSquare.Mul(vInverseSquare, minusW2)}, []*operation.Point{L[i], R[i]})
+		for j := 0; j < N; j++ {
+			if j&(1<<uint(logN-i-1)) != 0 {
+				s[j].Mul(&s[j], v)
+				sInverse[j].Mul(&sInverse[j], vInverse)
+			} else {
+				s[j].Mul(&s[j], vInverse)
+				sInverse[j].Mul(&sInverse[j], v)
+			}
+		}
+	}
+	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), maxExp)
+	zTmp := new(operation.Scalar).Set(z)
+	for j := 0; j < numValuePad; j++ {
+		zTmp.Mul(zTmp, z)
+		sInverse[j*maxExp:(j+1)*maxExp].MulScalarAdd(twoVectorN, new(operation.Scalar).Sub(operation.ScZero, zTmp), sInverse[j*maxExp:(j+1)*maxExp])
+	}
+	zNeg := new(operation.Scalar).Sub(operation.ScZero, z)
+	s.AddScalar(s, z).MulScalar(s, w2)
+	sInverse.Hadamard(sInverse, prepareHPrime(yInverse, N)).AddScalar(sInverse, zNeg).MulScalar(sInverse, w2)
+	bv.gScalars[:N].Add(bv.gScalars[:N], s)
+	bv.hScalars[:N].Add(bv.hScalars[:N], sInverse)
+
+	ab := new(operation.Scalar).Mul(proof.innerProductProof.a, proof.innerProductProof.b)
+	uScalar := new(operation.Scalar).Sub(ab, proof.tHat)
+	bv.uScalar.MulAdd(uScalar.Mul(uScalar, hashX), w2, bv.uScalar)
+	bv.hBase.MulAdd(proof.mu, w2, bv.hBase)
+	bv.pending.Append([]*operation.Scalar{minusW2, new(operation.Scalar).Mul(minusW2, x)}, []*operation.Point{proof.a, proof.s})
+
+	bv.count++
+	if len(bv.pending.scalars)+len(bv.tags) >= batchVerifierFlushTerms {
+		bv.flush()
+	}
+	return nil
+}
+
+// flush multiplies out the pending proof-specific and asset tag terms into the running point
+func (bv *BatchVerifier) flush() {
+	for key, tag := range bv.tags {
+		bv.pending.AppendSingle(bv.tagScalars[key], tag)
+	}
+	bv.tagScalars = map[string]*operation.Scalar{}
+	bv.tags = map[string]*operation.Point{}
+	if len(bv.pending.scalars) > 0 {
+		bv.acc.Add(bv.acc, bv.pending.Execute())
+	}
+}
+
+// Verify reports whether every proof added so far is valid. More proofs may be added and Verify called again.
+// An empty batch is valid.
+func (bv *BatchVerifier) Verify() (bool, error) {
+	bv.flush()
+	builder := NewMSMultBuilder(true).SetWorkers(MSMWorkers)
+	builder.AppendTable(bv.gScalars.Ptrs(), bv.params.gTable, 0)
+	builder.AppendTable(bv.hScalars.Ptrs(), bv.params.hTable, 0)
+	result := builder.Execute()
+	result.Add(result, new(operation.Point).ScalarMultTable(bv.params.uTable, bv.uScalar))
+	result.Add(result, new(operation.Point).ScalarMultTable(bv.params.blindingTable, bv.hBase))
+	result.Add(result, bv.params.Commit(bv.valueBase, bv.randomBase))
+	result.Add(result, bv.acc)
+	if !result.IsIdentity() {
+		Logger.Log.Errorf("batch verifier: verify aggregated range proofs failed")
+		return false, fmt.Errorf("batch verify of %d aggregated range proofs failed", bv.count)
+	}
+	return true, nil
+}
+
+// VerifyBatchUsingBase verifies proofs like VerifyBatch, with proofs[i] made over the value base assetTags[i].
+// A nil asset tag marks a PRV proof, so PRV and CA proofs can be mixed in one batch; every asset tag is folded
+// into the single multi-scalar multiplication. Like VerifyBatch, the index is that of the first malformed proof, or -1.
+func VerifyBatchUsingBase(proofs []*AggregatedRangeProof, assetTags []*operation.Point) (bool, error, int) {
+	if len(assetTags) != len(proofs) {
+		return false, fmt.Errorf("got %d asset tags for %d proofs", len(assetTags), len(proofs)), -1
+	}
+	bv := NewBatchVerifier()
+	for k, proof := range proofs {
+		var err error
+		if assetTags[k] == nil {
+			err = bv.Add(proof)
+		} else {
+			err = bv.AddUsingBase(proof, assetTags[k])
+		}
+		if err != nil {
+			return false, err, k
+		}
+	}
+	valid, err := bv.Verify()
+	return valid, err, -1
+}
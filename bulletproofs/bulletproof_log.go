@@ -1,12 +1,17 @@
 package bulletproofs
 
-import "github.com/incognitochain/incognito-chain/common"
+// LevelLogger is the part of a level-based logger the package writes to.
+// incognito-chain's common.Logger satisfies it.
+type LevelLogger interface {
+	Error(v ...interface{})
+	Errorf(format string, params ...interface{})
+}
 
 type logger struct {
-	Log common.Logger
+	Log LevelLogger
 }
 
-func (lg *logger) Init(inst common.Logger) {
+func (lg *logger) Init(inst LevelLogger) {
 	lg.Log = inst
 }
 
@@ -4,10 +4,11 @@
 package bulletproofs
 
 import (
+	"context"
+	"encoding/binary"
 	"math"
 
 	"github.com/dat-incognito-org/newbp/operation"
-	"github.com/incognitochain/incognito-chain/privacy/privacy_util"
 	"github.com/pkg/errors"
 )
 
@@ -16,6 +17,8 @@ import (
 type AggregatedRangeWitness struct {
 	values []uint64
 	rands  []*operation.Scalar
+	// bitWidth is the number of bits every value is proven to fit in; 0 means DefaultBitWidth
+	bitWidth int
 }
 
 // AggregatedRangeProof is the struct for Bulletproof.
@@ -30,6 +33,99 @@ type AggregatedRangeProof struct {
 	tHat              *operation.Scalar
 	mu                *operation.Scalar
 	innerProductProof *InnerProductProof
+	// bitWidth is the number of bits the values are proven to fit in; 0 means DefaultBitWidth
+	bitWidth int
+}
+
+// DefaultBitWidth is the range width of legacy proofs: values lie in [0, 2^64)
+const DefaultBitWidth = MaxExp
+
+// bitWidthFlag marks a proof whose width is not DefaultBitWidth. Such a proof starts with one extra byte,
+// bitWidthFlag | bitWidth, before the output count; a legacy proof starts with the output count, which never has
+// this bit set. The same byte is appended to the transcript seed, so the width is bound into every challenge.
+const bitWidthFlag = 0x80
+
+// ProofVersion2 is the first byte of the v2 wire format of an AggregatedRangeProof:
+//
+//	ProofVersion2 | bitWidth | uvarint(len(cmsValue)) | cmsValue | A | S | T1 | T2 | tauX | tHat | mu | inner product proof
+//
+// It is bitWidthFlag together with a width no proof uses, so v1 decoders reject v2 proofs instead of misreading them.
+// The v1 format is the one of Bytes and SetBytes; ParseAggregatedRangeProof reads both.
+const ProofVersion2 = bitWidthFlag | 0x02
+
+// ValidBitWidth reports whether proofs can be made for values of n bits: 8, 16, 32 or 64
+func ValidBitWidth(n int) bool {
+	return n == 8 || n == 16 || n == 32 || n == 64
+}
+
+func normalizeBitWidth(n int) int {
+	if n == 0 {
+		return DefaultBitWidth
+	}
+	return n
+}
+
+// BitWidth returns the number of bits the proof's values are proven to fit in
+func (proof AggregatedRangeProof) BitWidth() int {
+	return normalizeBitWidth(proof.bitWidth)
+}
+
+// dimensions checks the output count and bit-width of the proof against its inner product argument,
+// and returns the width, the padded output count and the number of generators the proof uses
+func (proof AggregatedRangeProof) dimensions() (bitWidth, numValuePad, N int, err error) {
+	numValue := len(proof.cmsValue)
+	if numValue > MaxOutputCoin {
+		return 0, 0, 0, errors.New("Must less than MaxOutputNumber")
+	}
+	bitWidth = proof.BitWidth()
+	if !ValidBitWidth(bitWidth) {
+		return 0, 0, 0, errors.Errorf("invalid range proof bit-width %d", bitWidth)
+	}
+	numValuePad = roundUpPowTwo(numValue)
+	N = bitWidth * numValuePad
+	if proof.innerProductProof == nil || len(proof.innerProductProof.l) != int(math.Log2(float64(N))) {
+		return 0, 0, 0, errors.Errorf("inner product proof does not match a %d-bit range proof for %d outputs", bitWidth, numValue)
+	}
+	return bitWidth, numValuePad, N, nil
+}
+
+// transcriptSeed is the initial hash input of the challenges: the commitment scheme's cs, followed by the
+// width byte for proofs that are not DefaultBitWidth, so legacy proofs keep their transcript
+func transcriptSeed(cs *operation.Point, bitWidth int) []byte {
+	seed := cs.ToBytesS()
+	if bitWidth != DefaultBitWidth {
+		seed = append(seed, byte(bitWidthFlag|bitWidth))
+	}
+	return seed
+}
+
+// transcriptDomainV2 separates v2 transcripts from the legacy one and from every other hash in the package
+const transcriptDomainV2 = "newbp/bulletproofs/transcript/v2"
+
+// rangeTranscript selects how the first challenge y is seeded; z, x and the inner product challenges chain from y.
+// The legacy transcript only absorbs cs and the width byte. The v2 transcript absorbs the whole statement
+// (cs, bit-width, output count and every value commitment) and a caller-supplied context, so a proof is bound
+// to its commitments and to, e.g., the hash of the transaction that carries it.
+type rangeTranscript struct {
+	v2      bool
+	context []byte
+}
+
+var legacyTranscript = rangeTranscript{}
+
+func (tr rangeTranscript) seed(cs *operation.Point, bitWidth int, cmsValue []*operation.Point) []byte {
+	if !tr.v2 {
+		return transcriptSeed(cs, bitWidth)
+	}
+	data := appendLengthPrefixed(nil, []byte(transcriptDomainV2))
+	data = append(data, cs.ToBytesS()...)
+	data = append(data, byte(bitWidth), byte(len(cmsValue)))
+	for _, cm := range cmsValue {
+		data = append(data, cm.ToBytesS()...)
+	}
+	data = appendLengthPrefixed(data, tr.context)
+	seed := operation.Keccak256(data)
+	return seed[:]
 }
 
 type bulletproofParams struct {
@@ -37,23 +133,37 @@ type bulletproofParams struct {
 	h  []*operation.Point
 	u  *operation.Point
 	cs *operation.Point
+	// uTable is the precomputed fixed-base table for u
+	uTable *operation.FixedBaseTable
+	// gTable and hTable cache MSM lookup tables for g and h
+	gTable *operation.MSMTable
+	hTable *operation.MSMTable
 }
 
-var AggParam = newBulletproofParams(privacy_util.MaxOutputCoin)
+// MaxOutputCoin is the largest number of values one proof may aggregate
+const MaxOutputCoin = 32
+
+// MaxExp is the bit length of a value in a legacy range proof
+const MaxExp = 64
+
+var AggParam = newBulletproofParams(MaxOutputCoin)
+
+// MSMWorkers is the number of goroutines the verifiers may use for their large multi-scalar multiplications.
+// The default of 1 keeps verification on the calling goroutine; a non-positive value uses GOMAXPROCS.
+var MSMWorkers = 1
 
 // ValidateSanity performs sanity checks for this proof.
+// All points must lie in the prime-order subgroup; A, S, T1 and T2 must not be the identity.
 func (proof AggregatedRangeProof) ValidateSanity() bool {
 	for i := 0; i < len(proof.cmsValue); i++ {
 		if !proof.cmsValue[i].PointValid() {
 			return false
 		}
 	}
-	if !proof.a.PointValid() || !proof.s.PointValid() || !proof.t1.PointValid() || !proof.t2.PointValid() {
-		return false
-	}
-	if !proof.tauX.ScalarValid() || !proof.tHat.ScalarValid() || !proof.mu.ScalarValid() {
+	if !proof.a.PointValidNonIdentity() || !proof.s.PointValidNonIdentity() || !proof.t1.PointValidNonIdentity() || !proof.t2.PointValidNonIdentity() {
 		return false
 	}
+	// scalars need no check: SetBytes rejects non-canonical encodings
 
 	return proof.innerProductProof.ValidateSanity()
 }
@@ -96,15 +206,36 @@ func (proof AggregatedRangeProof) IsNil() bool {
 	return proof.innerProductProof == nil
 }
 
-// Bytes does byte-marshalling
+// Bytes does byte-marshalling into the v1 format. Its output count is a single byte below bitWidthFlag, so a proof
+// with more commitments has no v1 encoding and Bytes returns empty bytes for it; use BytesV2.
 func (proof AggregatedRangeProof) Bytes() []byte {
 	var res []byte
 
-	if proof.IsNil() {
+	if proof.IsNil() || len(proof.cmsValue) >= bitWidthFlag {
 		return []byte{}
 	}
 
+	if bitWidth := proof.BitWidth(); bitWidth != DefaultBitWidth {
+		res = append(res, byte(bitWidthFlag|bitWidth))
+	}
 	res = append(res, byte(len(proof.cmsValue)))
+	return proof.appendBody(res)
+}
+
+// BytesV2 returns the v2 encoding of the proof, see ProofVersion2
+func (proof AggregatedRangeProof) BytesV2() []byte {
+	if proof.IsNil() {
+		return []byte{}
+	}
+
+	res := []byte{ProofVersion2, byte(proof.BitWidth())}
+	var count [binary.MaxVarintLen64]byte
+	res = append(res, count[:binary.PutUvarint(count[:], uint64(len(proof.cmsValue)))]...)
+	return proof.appendBody(res)
+}
+
+// appendBody appends everything after the output count, which both wire formats share
+func (proof AggregatedRangeProof) appendBody(res []byte) []byte {
 	for i := 0; i < len(proof.cmsValue); i++ {
 		res = append(res, proof.cmsValue[i].ToBytesS()...)
 	}
@@ -129,21 +260,111 @@ func (proof *AggregatedRangeProof) SetCommitments(cmsValue []*operation.Point) {
 	proof.cmsValue = cmsValue
 }
 
+// SetBytes parses the v1 format of Bytes. It rejects empty input and any byte past the end of the proof.
 func (proof *AggregatedRangeProof) SetBytes(bytes []byte) error {
 	if len(bytes) == 0 {
-		return nil
+		return errors.New("Range Proof unmarshaling from bytes failed: empty input")
 	}
 
-	lenValues := int(bytes[0])
-	offset := 1
-	var err error
+	offset := 0
+	proof.bitWidth = DefaultBitWidth
+	if bytes[0]&bitWidthFlag != 0 {
+		proof.bitWidth = int(bytes[0] &^ bitWidthFlag)
+		if proof.bitWidth == DefaultBitWidth || !ValidBitWidth(proof.bitWidth) {
+			return errors.New("Range Proof unmarshaling from bytes failed: invalid bit-width")
+		}
+		offset++
+	}
+	if offset >= len(bytes) {
+		return errors.New("Range Proof unmarshaling from bytes failed")
+	}
+	lenValues := int(bytes[offset])
+	if lenValues >= bitWidthFlag {
+		return errors.New("Range Proof unmarshaling from bytes failed: invalid output count")
+	}
+	offset++
+	if err := proof.setBody(bytes, offset, lenValues); err != nil {
+		return err
+	}
+	if len(bytes) != proof.encodedSize(false) {
+		return errors.New("Range Proof unmarshaling from bytes failed: unexpected length")
+	}
+	return nil
+}
 
+// SetBytesV2 parses the v2 encoding, see ProofVersion2. Like SetBytes, it rejects empty input and any byte past the
+// end of the proof; it also rejects a non-minimal output count.
+func (proof *AggregatedRangeProof) SetBytesV2(bytes []byte) error {
+	if len(bytes) < 2 || bytes[0] != ProofVersion2 {
+		return errors.New("Range Proof unmarshaling from bytes failed: not a v2 proof")
+	}
+	bitWidth := int(bytes[1])
+	if !ValidBitWidth(bitWidth) {
+		return errors.New("Range Proof unmarshaling from bytes failed: invalid bit-width")
+	}
+	lenValues, n := binary.Uvarint(bytes[2:])
+	var minimal [binary.MaxVarintLen64]byte
+	if n <= 0 || binary.PutUvarint(minimal[:], lenValues) != n {
+		return errors.New("Range Proof unmarshaling from bytes failed: invalid output count")
+	}
+	offset := 2 + n
+	// bound the count by the input before allocating for it
+	if lenValues > uint64(len(bytes)-offset)/operation.Ed25519KeySize {
+		return errors.New("Range Proof unmarshaling from bytes failed")
+	}
+	proof.bitWidth = bitWidth
+	if err := proof.setBody(bytes, offset, int(lenValues)); err != nil {
+		return err
+	}
+	if len(bytes) != proof.encodedSize(true) {
+		return errors.New("Range Proof unmarshaling from bytes failed: unexpected length")
+	}
+	return nil
+}
+
+// ParseAggregatedRangeProof decodes a proof in either wire format, telling them apart by the first byte.
+// Both are decoded strictly: empty input and trailing bytes are rejected.
+func ParseAggregatedRangeProof(bytes []byte) (*AggregatedRangeProof, error) {
+	if len(bytes) == 0 {
+		return nil, errors.New("Range Proof unmarshaling from bytes failed: empty input")
+	}
+	proof := new(AggregatedRangeProof)
+	if bytes[0] == ProofVersion2 {
+		if err := proof.SetBytesV2(bytes); err != nil {
+			return nil, err
+		}
+		return proof, nil
+	}
+	if err := proof.SetBytes(bytes); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// encodedSize returns the length of the v1 or v2 encoding of a complete proof
+func (proof AggregatedRangeProof) encodedSize(v2 bool) int {
+	size := 1
+	switch {
+	case v2:
+		var count [binary.MaxVarintLen64]byte
+		size = 2 + binary.PutUvarint(count[:], uint64(len(proof.cmsValue)))
+	case proof.BitWidth() != DefaultBitWidth:
+		size++
+	}
+	// A, S, T1, T2, tauX, tHat, mu, then the inner product proof: lenL, L, R, a, b, p
+	size += (len(proof.cmsValue) + 7) * operation.Ed25519KeySize
+	return size + 1 + (2*len(proof.innerProductProof.l)+3)*operation.Ed25519KeySize
+}
+
+// setBody parses everything after the output count, which both wire formats share
+func (proof *AggregatedRangeProof) setBody(bytes []byte, offset int, lenValues int) error {
+	var err error
 	proof.cmsValue = make([]*operation.Point, lenValues)
 	for i := 0; i < lenValues; i++ {
 		if offset+operation.Ed25519KeySize > len(bytes) {
 			return errors.New("Range Proof unmarshaling from bytes failed")
 		}
-		proof.cmsValue[i], err = new(operation.Point).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+		proof.cmsValue[i], err = new(operation.Point).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
 		if err != nil {
 			return err
 		}
@@ -153,7 +374,7 @@ func (proof *AggregatedRangeProof) SetBytes(bytes []byte) error {
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return errors.New("Range Proof unmarshaling from bytes failed")
 	}
-	proof.a, err = new(operation.Point).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.a, err = new(operation.Point).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
 	if err != nil {
 		return err
 	}
@@ -162,7 +383,7 @@ func (proof *AggregatedRangeProof) SetBytes(bytes []byte) error {
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return errors.New("Range Proof unmarshaling from bytes failed")
 	}
-	proof.s, err = new(operation.Point).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.s, err = new(operation.Point).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
 	if err != nil {
 		return err
 	}
@@ -171,7 +392,7 @@ func (proof *AggregatedRangeProof) SetBytes(bytes []byte) error {
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return errors.New("Range Proof unmarshaling from bytes failed")
 	}
-	proof.t1, err = new(operation.Point).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.t1, err = new(operation.Point).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
 	if err != nil {
 		return err
 	}
@@ -180,7 +401,7 @@ func (proof *AggregatedRangeProof) SetBytes(bytes []byte) error {
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return errors.New("Range Proof unmarshaling from bytes failed")
 	}
-	proof.t2, err = new(operation.Point).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.t2, err = new(operation.Point).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
 	if err != nil {
 		return err
 	}
@@ -189,19 +410,28 @@ func (proof *AggregatedRangeProof) SetBytes(bytes []byte) error {
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return errors.New("Range Proof unmarshaling from bytes failed")
 	}
-	proof.tauX = new(operation.Scalar).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.tauX, err = new(operation.Scalar).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
+	if err != nil {
+		return err
+	}
 	offset += operation.Ed25519KeySize
 
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return errors.New("Range Proof unmarshaling from bytes failed")
 	}
-	proof.tHat = new(operation.Scalar).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.tHat, err = new(operation.Scalar).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
+	if err != nil {
+		return err
+	}
 	offset += operation.Ed25519KeySize
 
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return errors.New("Range Proof unmarshaling from bytes failed")
 	}
-	proof.mu = new(operation.Scalar).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.mu, err = new(operation.Scalar).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
+	if err != nil {
+		return err
+	}
 	offset += operation.Ed25519KeySize
 
 	if offset >= len(bytes) {
@@ -225,405 +455,99 @@ func (wit *AggregatedRangeWitness) Set(values []uint64, rands []*operation.Scala
 	}
 }
 
-func (wit AggregatedRangeWitness) Prove() (*AggregatedRangeProof, error) {
-	proof := new(AggregatedRangeProof)
-	numValue := len(wit.values)
-	if numValue > privacy_util.MaxOutputCoin {
-		return nil, errors.New("Must less than MaxOutputCoin")
-	}
-	numValuePad := roundUpPowTwo(numValue)
-	maxExp := privacy_util.MaxExp
-	N := maxExp * numValuePad
-
-	aggParam := setAggregateParams(N)
-
-	values := make([]uint64, numValuePad)
-	rands := make([]*operation.Scalar, numValuePad)
-	for i := range wit.values {
-		values[i] = wit.values[i]
-		rands[i] = new(operation.Scalar).Set(wit.rands[i])
-	}
-	for i := numValue; i < numValuePad; i++ {
-		values[i] = uint64(0)
-		rands[i] = new(operation.Scalar).FromUint64(0)
-	}
-
-	proof.cmsValue = make([]*operation.Point, numValue)
-	for i := 0; i < numValue; i++ {
-		proof.cmsValue[i] = operation.PedCom.CommitAtIndex(new(operation.Scalar).FromUint64(values[i]), rands[i], operation.PedersenValueIndex)
-	}
-	// Convert values to binary array
-	aL := make([]*operation.Scalar, N)
-	aR := make([]*operation.Scalar, N)
-	sL := make([]*operation.Scalar, N)
-	sR := make([]*operation.Scalar, N)
-
-	for i, value := range values {
-		tmp := ConvertUint64ToBinary(value, maxExp)
-		for j := 0; j < maxExp; j++ {
-			aL[i*maxExp+j] = tmp[j]
-			aR[i*maxExp+j] = new(operation.Scalar).Sub(tmp[j], new(operation.Scalar).FromUint64(1))
-			sL[i*maxExp+j] = operation.RandomScalar()
-			sR[i*maxExp+j] = operation.RandomScalar()
-		}
-	}
-	// LINE 40-50
-	// Commitment to aL, aR: A = h^alpha * G^aL * H^aR
-	// Commitment to sL, sR : S = h^rho * G^sL * H^sR
-	var alpha, rho *operation.Scalar
-	alpha = operation.RandomScalar()
-	rho = operation.RandomScalar()
-	msmBuilder := NewMSMultBuilder(false)
-	_, err := encodeVectors(aL, aR, aggParam.g, aggParam.h, msmBuilder)
-	if err != nil {
-		return nil, err
-	}
-	msmBuilder.AppendSingle(alpha, operation.HBase)
-	proof.a = msmBuilder.Execute()
-
-	_, err = encodeVectors(sL, sR, aggParam.g, aggParam.h, msmBuilder)
-	if err != nil {
-		return nil, err
-	}
-	msmBuilder.AppendSingle(rho, operation.HBase)
-	proof.s = msmBuilder.Execute()
-	// challenge y, z
-	y := generateChallenge(aggParam.cs.ToBytesS(), []*operation.Point{proof.a, proof.s})
-	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a, proof.s})
-
-	// LINE 51-54
-	twoNumber := new(operation.Scalar).FromUint64(2)
-	twoVectorN := powerVector(twoNumber, maxExp)
-
-	// HPrime = H^(y^(1-i)
-	HPrime := computeHPrime(y, N, aggParam.h)
-
-	// l(X) = (aL -z*1^n) + sL*X; r(X) = y^n hada (aR +z*1^n + sR*X) + z^2 * 2^n
-	yVector := powerVector(y, N)
-	hadaProduct, err := hadamardProduct(yVector, vectorAddScalar(aR, z))
-	if err != nil {
-		return nil, err
+// checkBitWidth returns the witness's bit-width, after checking that every value fits in it
+func (wit AggregatedRangeWitness) checkBitWidth() (int, error) {
+	bitWidth := normalizeBitWidth(wit.bitWidth)
+	if !ValidBitWidth(bitWidth) {
+		return 0, errors.Errorf("invalid range proof bit-width %d", bitWidth)
 	}
-	vectorSum := make([]*operation.Scalar, N)
-	zTmp := new(operation.Scalar).Set(z)
-	for j := 0; j < numValuePad; j++ {
-		zTmp.Mul(zTmp, z)
-		for i := 0; i < maxExp; i++ {
-			vectorSum[j*maxExp+i] = new(operation.Scalar).Mul(twoVectorN[i], zTmp)
+	for i, v := range wit.values {
+		if bitWidth < 64 && v>>uint(bitWidth) != 0 {
+			return 0, errors.Errorf("value at index %d does not fit in %d bits", i, bitWidth)
 		}
 	}
-	zNeg := new(operation.Scalar).Sub(new(operation.Scalar).FromUint64(0), z)
-	l0 := vectorAddScalar(aL, zNeg)
-	l1 := sL
-	var r0, r1 []*operation.Scalar
-	if r0, err = vectorAdd(hadaProduct, vectorSum); err != nil {
-		return nil, err
-	} else if r1, err = hadamardProduct(yVector, sR); err != nil {
-		return nil, err
-	}
-
-	// t(X) = <l(X), r(X)> = t0 + t1*X + t2*X^2
-	// t1 = <l1, ro> + <l0, r1>, t2 = <l1, r1>
-	var t1, t2 *operation.Scalar
-	if ip3, err := innerProduct(l1, r0); err != nil {
-		return nil, err
-	} else if ip4, err := innerProduct(l0, r1); err != nil {
-		return nil, err
-	} else {
-		t1 = new(operation.Scalar).Add(ip3, ip4)
-		if t2, err = innerProduct(l1, r1); err != nil {
-			return nil, err
-		}
-	}
-
-	// commitment to t1, t2
-	tau1 := operation.RandomScalar()
-	tau2 := operation.RandomScalar()
-	proof.t1 = operation.PedCom.CommitAtIndex(t1, tau1, operation.PedersenValueIndex)
-	proof.t2 = operation.PedCom.CommitAtIndex(t2, tau2, operation.PedersenValueIndex)
-
-	x := generateChallenge(z.ToBytesS(), []*operation.Point{proof.t1, proof.t2})
-	xSquare := new(operation.Scalar).Mul(x, x)
-
-	// lVector = aL - z*1^n + sL*x
-	// rVector = y^n hada (aR +z*1^n + sR*x) + z^2*2^n
-	// tHat = <lVector, rVector>
-	lVector, err := vectorAdd(vectorAddScalar(aL, zNeg), vectorMulScalar(sL, x))
-	if err != nil {
-		return nil, err
-	}
-	tmpVector, err := vectorAdd(vectorAddScalar(aR, z), vectorMulScalar(sR, x))
-	if err != nil {
-		return nil, err
-	}
-	rVector, err := hadamardProduct(yVector, tmpVector)
-	if err != nil {
-		return nil, err
-	}
-	rVector, err = vectorAdd(rVector, vectorSum)
-	if err != nil {
-		return nil, err
-	}
-	proof.tHat, err = innerProduct(lVector, rVector)
-	if err != nil {
-		return nil, err
-	}
+	return bitWidth, nil
+}
 
-	// blinding value for tHat: tauX = tau2*x^2 + tau1*x + z^2*rand
-	proof.tauX = new(operation.Scalar).Mul(tau2, xSquare)
-	proof.tauX.Add(proof.tauX, new(operation.Scalar).Mul(tau1, x))
-	zTmp = new(operation.Scalar).Set(z)
-	tmpBN := new(operation.Scalar)
-	for j := 0; j < numValuePad; j++ {
-		zTmp.Mul(zTmp, z)
-		proof.tauX.Add(proof.tauX, tmpBN.Mul(zTmp, rands[j]))
+// SetBitWidth sets the number of bits the values are proven to fit in, one of 8, 16, 32 or 64.
+// Prove fails if a value does not fit.
+func (wit *AggregatedRangeWitness) SetBitWidth(bitWidth int) error {
+	if !ValidBitWidth(bitWidth) {
+		return errors.Errorf("invalid range proof bit-width %d", bitWidth)
 	}
+	wit.bitWidth = bitWidth
+	return nil
+}
 
-	// alpha, rho blind A, S
-	// mu = alpha + rho*x
-	proof.mu = new(operation.Scalar).Add(alpha, new(operation.Scalar).Mul(rho, x))
+// Prove creates a range proof using crypto/rand for the blinding values
+func (wit AggregatedRangeWitness) Prove() (*AggregatedRangeProof, error) {
+	return wit.ProveWithRand(nil)
+}
 
-	// instead of sending left vector and right vector, we use inner sum argument to reduce proof size from 2*n to 2(log2(n)) + 2
-	innerProductWit := new(InnerProductWitness)
-	innerProductWit.a = lVector
-	innerProductWit.b = rVector
-	uPrime := new(operation.Point).ScalarMult(aggParam.u, operation.HashToScalar(x.ToBytesS()))
+// ProveWithRand is Prove with the blinding values drawn from r, in the order sL, sR, alpha, rho, tau1, tau2.
+// A nil r means crypto/rand. A seeded source makes the proof reproducible, which is only meant for tests:
+// a proof whose blinding values are known reveals the committed values.
+func (wit AggregatedRangeWitness) ProveWithRand(r operation.RandomSource) (*AggregatedRangeProof, error) {
+	return wit.prove(r, legacyTranscript)
+}
 
-	_, err = encodeVectors(lVector, rVector, aggParam.g, HPrime, msmBuilder)
-	if err != nil {
-		return nil, err
-	}
-	msmBuilder.AppendSingle(proof.tHat, uPrime)
-	innerProductWit.p = msmBuilder.Execute()
+// ProveWithContext creates a range proof over the v2 transcript, which binds the commitments, the output count,
+// the bit-width and context into the challenges. It must be verified with VerifyWithContext and the same context.
+func (wit AggregatedRangeWitness) ProveWithContext(context []byte) (*AggregatedRangeProof, error) {
+	return wit.prove(nil, rangeTranscript{v2: true, context: context})
+}
 
-	proof.innerProductProof, err = innerProductWit.Prove(aggParam.g, HPrime, uPrime, x.ToBytesS())
-	if err != nil {
-		return nil, err
-	}
+func (wit AggregatedRangeWitness) prove(r operation.RandomSource, tr rangeTranscript) (*AggregatedRangeProof, error) {
+	return DefaultRangeProofParams().prove(context.Background(), &wit, r, tr, nil)
+}
 
-	return proof, nil
+// ProveContext is Prove that stops with ctx.Err() once ctx is done, such as when the caller has gone away.
+// It is checked between the rounds of the protocol and between the folding rounds of the inner product argument.
+func (wit AggregatedRangeWitness) ProveContext(ctx context.Context) (*AggregatedRangeProof, error) {
+	return DefaultRangeProofParams().ProveContext(ctx, &wit)
 }
 
 // Verify does verification for this Bulletproof.
 // No view into chain data is needed.
 func (proof AggregatedRangeProof) Verify() (bool, error) {
-	numValue := len(proof.cmsValue)
-	if numValue > privacy_util.MaxOutputCoin {
-		return false, errors.New("Must less than MaxOutputNumber")
-	}
-	numValuePad := roundUpPowTwo(numValue)
-	maxExp := privacy_util.MaxExp
-	N := numValuePad * maxExp
-	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), maxExp)
-	aggParam := setAggregateParams(N)
-
-	cmsValue := proof.cmsValue
-	for i := numValue; i < numValuePad; i++ {
-		cmsValue = append(cmsValue, new(operation.Point).Identity())
-	}
-
-	// recalculate challenge y, z
-	y := generateChallenge(aggParam.cs.ToBytesS(), []*operation.Point{proof.a, proof.s})
-	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a, proof.s})
-	zSquare := new(operation.Scalar).Mul(z, z)
-	zNeg := new(operation.Scalar).Sub(new(operation.Scalar).FromUint64(0), z)
-
-	x := generateChallenge(z.ToBytesS(), []*operation.Point{proof.t1, proof.t2})
-	xSquare := new(operation.Scalar).Mul(x, x)
-
-	// HPrime = H^(y^(1-i)
-	HPrime := computeHPrime(y, N, aggParam.h)
-
-	// g^tHat * h^tauX = V^(z^2) * g^delta(y,z) * T1^x * T2^(x^2)
-	yVector := powerVector(y, N)
-	deltaYZ, err := computeDeltaYZ(z, zSquare, yVector, N)
-	if err != nil {
-		return false, err
-	}
-
-	LHS := operation.PedCom.CommitAtIndex(proof.tHat, proof.tauX, operation.PedersenValueIndex)
-	RHS := new(operation.Point).ScalarMult(proof.t2, xSquare)
-	RHS.Add(RHS, operation.NewIdentityPoint().AddPedersen(deltaYZ, operation.PedCom.G[operation.PedersenValueIndex], x, proof.t1))
-
-	expVector := vectorMulScalar(powerVector(z, numValuePad), zSquare)
-	RHS.Add(RHS, new(operation.Point).VarTimeMultiScalarMult(expVector, cmsValue))
-
-	if !operation.IsPointEqual(LHS, RHS) {
-		Logger.Log.Errorf("verify aggregated range proof statement 1 failed")
-		return false, errors.New("verify aggregated range proof statement 1 failed")
-	}
-
-	// verify eq (66)
-	uPrime := new(operation.Point).ScalarMult(aggParam.u, operation.HashToScalar(x.ToBytesS()))
-
-	vectorSum := make([]*operation.Scalar, N)
-	zTmp := new(operation.Scalar).Set(z)
-	for j := 0; j < numValuePad; j++ {
-		zTmp.Mul(zTmp, z)
-		for i := 0; i < maxExp; i++ {
-			vectorSum[j*maxExp+i] = new(operation.Scalar).Mul(twoVectorN[i], zTmp)
-			vectorSum[j*maxExp+i].Add(vectorSum[j*maxExp+i], new(operation.Scalar).Mul(z, yVector[j*maxExp+i]))
-		}
-	}
-	tmpHPrime := new(operation.Point).VarTimeMultiScalarMult(vectorSum, HPrime)
-	tmpG := new(operation.Point).Set(aggParam.g[0])
-	for i := 1; i < N; i++ {
-		tmpG.Add(tmpG, aggParam.g[i])
-	}
-
-	ASx := new(operation.Point).Add(proof.a, new(operation.Point).ScalarMult(proof.s, x))
-	P := new(operation.Point).Add(new(operation.Point).ScalarMult(tmpG, zNeg), tmpHPrime)
-	P.Add(P, ASx)
-	P.Add(P, new(operation.Point).ScalarMult(uPrime, proof.tHat))
-	PPrime := new(operation.Point).Add(proof.innerProductProof.p, new(operation.Point).ScalarMult(operation.HBase, proof.mu))
-	if !operation.IsPointEqual(P, PPrime) {
-		Logger.Log.Errorf("verify aggregated range proof statement 2-1 failed")
-		return false, errors.New("verify aggregated range proof statement 2-1 failed")
-	}
+	return proof.verify(legacyTranscript)
+}
 
-	// verify eq (68)
-	innerProductArgValid := proof.innerProductProof.Verify(aggParam.g, HPrime, uPrime, x.ToBytesS())
-	if !innerProductArgValid {
-		Logger.Log.Errorf("verify aggregated range proof statement 2 failed")
-		return false, errors.New("verify aggregated range proof statement 2 failed")
-	}
+func (proof AggregatedRangeProof) verify(tr rangeTranscript) (bool, error) {
+	return DefaultRangeProofParams().verify(context.Background(), &proof, tr)
+}
 
-	return true, nil
+// VerifyContext is Verify that stops with ctx.Err() once ctx is done, checked like in ProveContext
+func (proof AggregatedRangeProof) VerifyContext(ctx context.Context) (bool, error) {
+	return DefaultRangeProofParams().VerifyContext(ctx, &proof)
 }
 
 func (proof AggregatedRangeProof) VerifyFaster() (bool, error) {
-	numValue := len(proof.cmsValue)
-	if numValue > privacy_util.MaxOutputCoin {
-		return false, errors.New("Must less than MaxOutputNumber")
-	}
-	numValuePad := roundUpPowTwo(numValue)
-	maxExp := privacy_util.MaxExp
-	N := maxExp * numValuePad
-	aggParam := setAggregateParams(N)
-	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), maxExp)
-
-	cmsValue := proof.cmsValue
-	for i := numValue; i < numValuePad; i++ {
-		cmsValue = append(cmsValue, new(operation.Point).Identity())
-	}
-
-	// recalculate challenge y, z
-	y := generateChallenge(aggParam.cs.ToBytesS(), []*operation.Point{proof.a, proof.s})
-	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a, proof.s})
-	zSquare := new(operation.Scalar).Mul(z, z)
-	zNeg := new(operation.Scalar).Sub(new(operation.Scalar).FromUint64(0), z)
-
-	x := generateChallenge(z.ToBytesS(), []*operation.Point{proof.t1, proof.t2})
-	xSquare := new(operation.Scalar).Mul(x, x)
-
-	// g^tHat * h^tauX = V^(z^2) * g^delta(y,z) * T1^x * T2^(x^2)
-	yVector := powerVector(y, N)
-	deltaYZ, err := computeDeltaYZ(z, zSquare, yVector, N)
-	if err != nil {
-		return false, err
-	}
-	// HPrime = H^(y^(1-i)
-	HPrime := prepareHPrime(y, N, aggParam.h)
-
-	st1Builder := NewMSMultBuilder(true)
-	// Verify eq (65)
-	// skip error for Append() calls since lengths are known to match
-	st1Builder.AppendSingle(xSquare, proof.t2)
-	st1Builder.Append([]*operation.Scalar{deltaYZ, x}, []*operation.Point{operation.PedCom.G[operation.PedersenValueIndex], proof.t1})
-	expVector := vectorMulScalar(powerVector(z, numValuePad), zSquare)
-	st1Builder.Append(expVector, cmsValue)
-	st1Builder.AppendWithMultiplier([]*operation.Scalar{proof.tHat, proof.tauX}, []*operation.Point{operation.PedCom.G[operation.PedersenValueIndex], operation.PedCom.G[operation.PedersenRandomnessIndex]}, operation.NewScalar().Set(operation.ScMinusOne))
-
-	// Verify eq (66)
-	st2Builder := NewMSMultBuilder(true)
-	vectorSum := make([]*operation.Scalar, N)
-	zTmp := new(operation.Scalar).Set(z)
-	for j := 0; j < numValuePad; j++ {
-		zTmp.Mul(zTmp, z)
-		for i := 0; i < maxExp; i++ {
-			vectorSum[j*maxExp+i] = new(operation.Scalar).Mul(twoVectorN[i], zTmp)
-			vectorSum[j*maxExp+i].Add(vectorSum[j*maxExp+i], new(operation.Scalar).Mul(z, yVector[j*maxExp+i]))
-		}
-	}
-	HPrime_vectorSum := HPrime.Clone()
-	for i, v := range vectorSum {
-		HPrime_vectorSum.scalars[i].Mul(HPrime_vectorSum.scalars[i], v)
-	}
-	st2Builder.Append(HPrime_vectorSum.scalars, HPrime_vectorSum.points)
-	tmpG := new(operation.Point).Set(aggParam.g[0])
-	for i := 1; i < N; i++ {
-		tmpG.Add(tmpG, aggParam.g[i])
-	}
-	st2Builder.AppendSingle(zNeg, tmpG)
-	st2Builder.Append([]*operation.Scalar{operation.NewScalar().FromUint64(1), x}, []*operation.Point{proof.a, proof.s}) // AS^x
-	st2Builder.AppendSingle(operation.NewScalar().Mul(proof.tHat, operation.HashToScalar(x.ToBytesS())), aggParam.u) // tHat.U'
-	st2Builder.AppendWithMultiplier([]*operation.Scalar{operation.NewScalar().FromUint64(1), proof.mu}, []*operation.Point{proof.innerProductProof.p, operation.HBase}, operation.NewScalar().Set(operation.ScMinusOne))
-
-	// Verify eq (68)
-	hashCache := x.ToBytesS()
-	L := proof.innerProductProof.l
-	R := proof.innerProductProof.r
-	s := make([]*operation.Scalar, N)
-	sInverse := make([]*operation.Scalar, N)
-	logN := int(math.Log2(float64(N)))
-	vSquareList := make([]*operation.Scalar, logN)
-	vInverseSquareList := make([]*operation.Scalar, logN)
-
-	for i := 0; i < N; i++ {
-		s[i] = new(operation.Scalar).Set(proof.innerProductProof.a)
-		sInverse[i] = new(operation.Scalar).Set(proof.innerProductProof.b)
-	}
-
-	for i := range L {
-		v := generateChallenge(hashCache, []*operation.Point{L[i], R[i]})
-		hashCache = v.ToBytesS()
-		vInverse := new(operation.Scalar).Invert(v)
-		vSquareList[i] = new(operation.Scalar).Mul(v, v)
-		vInverseSquareList[i] = new(operation.Scalar).Mul(vInverse, vInverse)
-
-		for j := 0; j < N; j++ {
-			if j&int(math.Pow(2, float64(logN-i-1))) != 0 {
-				s[j] = new(operation.Scalar).Mul(s[j], v)
-				sInverse[j] = new(operation.Scalar).Mul(sInverse[j], vInverse)
-			} else {
-				s[j] = new(operation.Scalar).Mul(s[j], vInverse)
-				sInverse[j] = new(operation.Scalar).Mul(sInverse[j], v)
-			}
-		}
-	}
-
-	st3Builder := NewMSMultBuilder(true)
-	c := new(operation.Scalar).Mul(proof.innerProductProof.a, proof.innerProductProof.b)
-	st3Builder.Append(s, aggParam.g)
-	HPrime_sInverse := HPrime.Clone()
-	for i, v := range sInverse {
-		HPrime_sInverse.scalars[i].Mul(HPrime_sInverse.scalars[i], v)
-	}
-	st3Builder.Append(HPrime_sInverse.scalars, HPrime_sInverse.points)
-	st3Builder.AppendSingle(operation.NewScalar().Mul(c, operation.HashToScalar(x.ToBytesS())), aggParam.u) // cU'
-	rhsBuilder := NewMSMultBuilder(true)
-	rhsBuilder.Append(vSquareList, L)
-	rhsBuilder.Append(vInverseSquareList, R)
-	rhsBuilder.AppendSingle(operation.NewScalar().FromUint64(1), proof.innerProductProof.p)
-	st3Builder.AppendWithMultiplier(rhsBuilder.scalars, rhsBuilder.points, operation.NewScalar().Set(operation.ScMinusOne))
+	return proof.verifyFaster(legacyTranscript)
+}
 
-	st1Builder.AppendWithMultiplier(st2Builder.scalars, st2Builder.points, operation.RandomScalar())
-	st1Builder.AppendWithMultiplier(st3Builder.scalars, st3Builder.points, operation.RandomScalar())
-	if !st1Builder.Execute().IsIdentity() {
-		Logger.Log.Errorf("verify aggregated range proof statement 2 failed")
-		return false, errors.New("verify aggregated range proof statement 2 failed")
-	}
+// VerifyWithContext verifies a proof made by ProveWithContext, over the v2 transcript with the given context
+func (proof AggregatedRangeProof) VerifyWithContext(context []byte) (bool, error) {
+	return proof.verifyFaster(rangeTranscript{v2: true, context: context})
+}
 
-	return true, nil
+func (proof AggregatedRangeProof) verifyFaster(tr rangeTranscript) (bool, error) {
+	return DefaultRangeProofParams().verifyFaster(&proof, tr)
 }
 
 // VerifyBatch verifies a list of Bulletproofs in batched fashion.
 // It saves time by using a multi-exponent operation.
 func VerifyBatch(proofs []*AggregatedRangeProof) (bool, error, int) {
-	maxExp := privacy_util.MaxExp
-	baseG := operation.PedCom.G[operation.PedersenValueIndex]
-	baseH := operation.PedCom.G[operation.PedersenRandomnessIndex]
+	return verifyBatch(context.Background(), proofs)
+}
 
+// VerifyBatchContext is VerifyBatch that stops with ctx.Err() once ctx is done. It is checked before each proof is
+// added to the batch and before the final multi-scalar multiplication; the index is -1 for a cancelled batch.
+func VerifyBatchContext(ctx context.Context, proofs []*AggregatedRangeProof) (bool, error, int) {
+	return verifyBatch(ctx, proofs)
+}
+
+func verifyBatch(ctx context.Context, proofs []*AggregatedRangeProof) (bool, error, int) {
 	sum_tHat := new(operation.Scalar).FromUint64(0)
 	sum_tauX := new(operation.Scalar).FromUint64(0)
 	list_x_alpha := make([]*operation.Scalar, 0)
@@ -642,22 +566,48 @@ func VerifyBatch(proofs []*AggregatedRangeProof) (bool, error, int) {
 	list_A := make([]*operation.Point, 0)
 	list_beta := make([]*operation.Scalar, 0)
 	list_LR := make([]*operation.Point, 0)
-	list_lVector := make([]*operation.Scalar, 0)
-	list_rVector := make([]*operation.Scalar, 0)
-	list_gVector := make([]*operation.Point, 0)
-	list_hVector := make([]*operation.Point, 0)
-
-	twoNumber := new(operation.Scalar).FromUint64(2)
-	twoVectorN := powerVector(twoNumber, maxExp)
+	// every proof reuses a prefix of the same g, h vectors, so their terms go over the static tables
+	gh_builder := NewMSMultBuilder(true).SetWorkers(MSMWorkers)
+
+	// recalculate challenges y, z, x and the inner product challenges of every proof first,
+	// so that all the inverses the batch needs come from a single inversion
+	list_y := make([]*operation.Scalar, len(proofs))
+	list_z := make([]*operation.Scalar, len(proofs))
+	list_x := make([]*operation.Scalar, len(proofs))
+	list_challenges := make([][]*operation.Scalar, len(proofs))
+	list_bitWidth := make([]int, len(proofs))
+	toInvert := make([]*operation.Scalar, 0)
+	for k, proof := range proofs {
+		if err := ctx.Err(); err != nil {
+			return false, err, -1
+		}
+		bitWidth, _, _, err := proof.dimensions()
+		if err != nil {
+			return false, err, k
+		}
+		list_bitWidth[k] = bitWidth
+		list_y[k] = generateChallenge(transcriptSeed(AggParam.cs, bitWidth), []*operation.Point{proof.a, proof.s})
+		list_z[k] = generateChallenge(list_y[k].ToBytesS(), []*operation.Point{proof.a, proof.s})
+		list_x[k] = generateChallenge(list_z[k].ToBytesS(), []*operation.Point{proof.t1, proof.t2})
+		list_challenges[k] = innerProductChallenges(list_x[k].ToBytesS(), proof.innerProductProof.l, proof.innerProductProof.r)
+		toInvert = append(toInvert, list_y[k])
+		toInvert = append(toInvert, list_challenges[k]...)
+	}
+	inverses, err := batchInverse(toInvert)
+	if err != nil {
+		return false, err, -1
+	}
 
 	for k, proof := range proofs {
-		numValue := len(proof.cmsValue)
-		if numValue > privacy_util.MaxOutputCoin {
-			return false, errors.New("Must less than MaxOutputNumber"), k
+		if err := ctx.Err(); err != nil {
+			return false, err, -1
 		}
+		numValue := len(proof.cmsValue)
 		numValuePad := roundUpPowTwo(numValue)
+		maxExp := list_bitWidth[k]
 		N := maxExp * numValuePad
 		aggParam := setAggregateParams(N)
+		twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), maxExp)
 
 		cmsValue := proof.cmsValue
 		for i := numValue; i < numValuePad; i++ {
@@ -665,10 +615,10 @@ func VerifyBatch(proofs []*AggregatedRangeProof) (bool, error, int) {
 			cmsValue = append(cmsValue, identity)
 		}
 
-		// recalculate challenge y, z, x
-		y := generateChallenge(aggParam.cs.ToBytesS(), []*operation.Point{proof.a, proof.s})
-		z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a, proof.s})
-		x := generateChallenge(z.ToBytesS(), []*operation.Point{proof.t1, proof.t2})
+		y, z, x := list_y[k], list_z[k], list_x[k]
+		challenges := list_challenges[k]
+		yInverse, challengeInverses := inverses[0], inverses[1:len(challenges)+1]
+		inverses = inverses[len(challenges)+1:]
 		zSquare := new(operation.Scalar).Mul(z, z)
 		xSquare := new(operation.Scalar).Mul(x, x)
 
@@ -679,90 +629,67 @@ func VerifyBatch(proofs []*AggregatedRangeProof) (bool, error, int) {
 
 		// Compute first equation check
 		yVector := powerVector(y, N)
-		deltaYZ, err := computeDeltaYZ(z, zSquare, yVector, N)
-		if err != nil {
-			return false, err, k
-		}
+		deltaYZ := computeDeltaYZ(z, zSquare, yVector, N, maxExp)
 		sum_tHat.Add(sum_tHat, new(operation.Scalar).Mul(alpha, new(operation.Scalar).Sub(proof.tHat, deltaYZ)))
 		sum_tauX.Add(sum_tauX, new(operation.Scalar).Mul(alpha, proof.tauX))
 
 		list_x_alpha = append(list_x_alpha, new(operation.Scalar).Mul(x, alpha))
 		list_x_beta = append(list_x_beta, new(operation.Scalar).Mul(x, beta))
 		list_xSquare = append(list_xSquare, new(operation.Scalar).Mul(xSquare, alpha))
-		tmp := vectorMulScalar(powerVector(z, numValuePad), new(operation.Scalar).Mul(zSquare, alpha))
-		list_zSquare = append(list_zSquare, tmp...)
+		tmp := powerVector(z, numValuePad)
+		list_zSquare = append(list_zSquare, tmp.MulScalar(tmp, new(operation.Scalar).Mul(zSquare, alpha)).Ptrs()...)
 
 		list_V = append(list_V, cmsValue...)
 		list_t1 = append(list_t1, proof.t1)
 		list_t2 = append(list_t2, proof.t2)
 
 		// Verify the second argument
-		hashCache := x.ToBytesS()
 		L := proof.innerProductProof.l
 		R := proof.innerProductProof.r
-		s := make([]*operation.Scalar, N)
-		sInverse := make([]*operation.Scalar, N)
+		s := operation.NewScalarVector(N).Fill(proof.innerProductProof.a)
+		sInverse := operation.NewScalarVector(N).Fill(proof.innerProductProof.b)
 		logN := int(math.Log2(float64(N)))
 		vSquareList := make([]*operation.Scalar, logN)
 		vInverseSquareList := make([]*operation.Scalar, logN)
 
-		for i := 0; i < N; i++ {
-			s[i] = new(operation.Scalar).Set(proof.innerProductProof.a)
-			sInverse[i] = new(operation.Scalar).Set(proof.innerProductProof.b)
-		}
-
 		for i := range L {
-			v := generateChallenge(hashCache, []*operation.Point{L[i], R[i]})
-			hashCache = v.ToBytesS()
-			vInverse := new(operation.Scalar).Invert(v)
+			v := challenges[i]
+			vInverse := challengeInverses[i]
 			vSquareList[i] = new(operation.Scalar).Mul(v, v)
 			vInverseSquareList[i] = new(operation.Scalar).Mul(vInverse, vInverse)
 
 			for j := 0; j < N; j++ {
 				if j&int(math.Pow(2, float64(logN-i-1))) != 0 {
-					s[j] = new(operation.Scalar).Mul(s[j], v)
-					sInverse[j] = new(operation.Scalar).Mul(sInverse[j], vInverse)
+					s[j].Mul(&s[j], v)
+					sInverse[j].Mul(&sInverse[j], vInverse)
 				} else {
-					s[j] = new(operation.Scalar).Mul(s[j], vInverse)
-					sInverse[j] = new(operation.Scalar).Mul(sInverse[j], v)
+					s[j].Mul(&s[j], vInverse)
+					sInverse[j].Mul(&sInverse[j], v)
 				}
 			}
 		}
 
-		lVector := make([]*operation.Scalar, N)
-		rVector := make([]*operation.Scalar, N)
-
-		vectorSum := make([]*operation.Scalar, N)
+		vectorSum := operation.NewScalarVector(N)
 		zTmp := new(operation.Scalar).Set(z)
 		for j := 0; j < numValuePad; j++ {
 			zTmp.Mul(zTmp, z)
-			for i := 0; i < maxExp; i++ {
-				vectorSum[j*maxExp+i] = new(operation.Scalar).Mul(twoVectorN[i], zTmp)
-			}
+			vectorSum[j*maxExp : (j+1)*maxExp].MulScalar(twoVectorN, zTmp)
 		}
-		yInverse := new(operation.Scalar).Invert(y)
-		yTmp := new(operation.Scalar).Set(y)
-		for j := 0; j < N; j++ {
-			yTmp.Mul(yTmp, yInverse)
-			lVector[j] = new(operation.Scalar).Add(s[j], z)
-			rVector[j] = new(operation.Scalar).Sub(sInverse[j], vectorSum[j])
-			rVector[j].Mul(rVector[j], yTmp)
-			rVector[j].Sub(rVector[j], z)
-
-			lVector[j].Mul(lVector[j], beta)
-			rVector[j].Mul(rVector[j], beta)
+		// lVector = beta * (s + z), rVector = beta * (y^(-n) hada (sInverse - vectorSum) - z); s and sInverse are reused
+		zNeg := new(operation.Scalar).Sub(operation.ScZero, z)
+		lVector := s.AddScalar(s, z)
+		lVector.MulScalar(lVector, beta)
+		rVector := sInverse.Sub(sInverse, vectorSum)
+		rVector.Hadamard(rVector, prepareHPrime(yInverse, N)).AddScalar(rVector, zNeg).MulScalar(rVector, beta)
+
+		if _, err := encodeVectorsTable(lVector, rVector, aggParam.gTable, aggParam.hTable, gh_builder); err != nil {
+			return false, err, k
 		}
 
-		list_lVector = append(list_lVector, lVector...)
-		list_rVector = append(list_rVector, rVector...)
-
 		tmp1 := new(operation.Point).MultiScalarMult(vSquareList, L)
 		tmp2 := new(operation.Point).MultiScalarMult(vInverseSquareList, R)
 		list_LR = append(list_LR, new(operation.Point).Add(tmp1, tmp2))
 
-		list_gVector = append(list_gVector, aggParam.g...)
-		list_hVector = append(list_hVector, aggParam.h...)
-
 		sum_mu.Add(sum_mu, new(operation.Scalar).Mul(proof.mu, beta))
 		ab := new(operation.Scalar).Mul(proof.innerProductProof.a, proof.innerProductProof.b)
 		absubthat := new(operation.Scalar).Sub(ab, proof.tHat)
@@ -772,15 +699,16 @@ func VerifyBatch(proofs []*AggregatedRangeProof) (bool, error, int) {
 		list_S = append(list_S, proof.s)
 	}
 
-	tmp1 := new(operation.Point).MultiScalarMult(list_lVector, list_gVector)
-	tmp2 := new(operation.Point).MultiScalarMult(list_rVector, list_hVector)
-	tmp3 := new(operation.Point).ScalarMult(AggParam.u, sum_absubthat)
-	tmp4 := new(operation.Point).ScalarMult(baseH, sum_mu)
-	LHSPrime := new(operation.Point).Add(tmp1, tmp2)
+	if err := ctx.Err(); err != nil {
+		return false, err, -1
+	}
+	tmp3 := new(operation.Point).ScalarMultTable(AggParam.uTable, sum_absubthat)
+	tmp4 := new(operation.Point).ScalarMultTable(operation.HBaseTable, sum_mu)
+	LHSPrime := gh_builder.Execute()
 	LHSPrime.Add(LHSPrime, tmp3)
 	LHSPrime.Add(LHSPrime, tmp4)
 
-	LHS := new(operation.Point).AddPedersen(sum_tHat, baseG, sum_tauX, baseH)
+	LHS := operation.PedCom.CommitAtIndex(sum_tHat, sum_tauX, operation.PedersenValueIndex)
 	LHSPrime.Add(LHSPrime, LHS)
 
 	tmp5 := new(operation.Point).MultiScalarMult(list_beta, list_A)
@@ -803,5 +731,14 @@ func VerifyBatch(proofs []*AggregatedRangeProof) (bool, error, int) {
 
 // EstimateMultiRangeProofSize returns the upper bound of Bulletproof size given the number of output coins.
 func EstimateMultiRangeProofSize(nOutput int) uint64 {
-	return uint64((nOutput+2*int(math.Log2(float64(privacy_util.MaxExp*roundUpPowTwo(nOutput))))+5)*operation.Ed25519KeySize + 5*operation.Ed25519KeySize + 2)
+	return EstimateMultiRangeProofSizeWithBitWidth(nOutput, DefaultBitWidth)
+}
+
+// EstimateMultiRangeProofSizeWithBitWidth is EstimateMultiRangeProofSize for proofs of the given bit-width
+func EstimateMultiRangeProofSizeWithBitWidth(nOutput int, bitWidth int) uint64 {
+	size := uint64((nOutput+2*int(math.Log2(float64(bitWidth*roundUpPowTwo(nOutput))))+5)*operation.Ed25519KeySize + 5*operation.Ed25519KeySize + 2)
+	if bitWidth != DefaultBitWidth {
+		size++
+	}
+	return size
 }
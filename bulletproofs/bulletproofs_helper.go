@@ -2,9 +2,9 @@ package bulletproofs
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/dat-incognito-org/newbp/operation"
-	"github.com/incognitochain/incognito-chain/privacy/privacy_util"
 )
 
 // ConvertIntToBinary represents a integer number in binary
@@ -27,79 +27,56 @@ func ConvertUint64ToBinary(number uint64, n int) []*operation.Scalar {
 }
 
 //nolint:gocritic // This function uses capitalized variable name
-func computeHPrime(y *operation.Scalar, N int, H []*operation.Point) []*operation.Point {
-	yInverse := new(operation.Scalar).Invert(y)
-	HPrime := make([]*operation.Point, N)
-	expyInverse := new(operation.Scalar).FromUint64(1)
-	for i := 0; i < N; i++ {
-		HPrime[i] = new(operation.Point).ScalarMult(H[i], expyInverse)
-		expyInverse.Mul(expyInverse, yInverse)
-	}
-	return HPrime
+func computeHPrime(yInverse *operation.Scalar, N int, H []*operation.Point) operation.PointVector {
+	return operation.NewPointVector(N).ScalarMult(operation.PointVectorFromSlice(H[:N]), prepareHPrime(yInverse, N))
 }
 
-func prepareHPrime(y *operation.Scalar, N int, H []*operation.Point) *msMultBuilder {
-	result := NewMSMultBuilder(true)
-	yInverse := new(operation.Scalar).Invert(y)
-	expyInverse := new(operation.Scalar).FromUint64(1)
-	for i := 0; i < N; i++ {
-		result.AppendSingle(operation.NewScalar().Set(expyInverse), H[i])
-		expyInverse.Mul(expyInverse, yInverse)
+// prepareHPrime returns y^(-i) for i < N, so that HPrime[i] = H[i]^(y^(-i)) can be folded into the scalars of H
+func prepareHPrime(yInverse *operation.Scalar, N int) operation.ScalarVector {
+	return powerVector(yInverse, N)
+}
+
+// innerProductChallenges recomputes the round challenges of an inner product argument
+func innerProductChallenges(hashCache []byte, L, R []*operation.Point) []*operation.Scalar {
+	challenges := make([]*operation.Scalar, len(L))
+	for i := range L {
+		challenges[i] = generateChallenge(hashCache, []*operation.Point{L[i], R[i]})
+		hashCache = challenges[i].ToBytesS()
 	}
-	return result
+	return challenges
+}
+
+// batchInverse returns the inverses of scLst with a single inversion, leaving scLst untouched
+func batchInverse(scLst []*operation.Scalar) ([]*operation.Scalar, error) {
+	result := operation.ScalarVectorFromSlice(scLst).Ptrs()
+	if err := operation.BatchInvert(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 //nolint:gocritic // This function uses capitalized variable name
-func computeDeltaYZ(z, zSquare *operation.Scalar, yVector []*operation.Scalar, N int) (*operation.Scalar, error) {
+func computeDeltaYZ(z, zSquare *operation.Scalar, yVector operation.ScalarVector, N int, bitWidth int) *operation.Scalar {
 	oneNumber := new(operation.Scalar).FromUint64(1)
 	twoNumber := new(operation.Scalar).FromUint64(2)
-	oneVectorN := powerVector(oneNumber, privacy_util.MaxExp)
-	twoVectorN := powerVector(twoNumber, privacy_util.MaxExp)
+	oneVectorN := powerVector(oneNumber, bitWidth)
+	twoVectorN := powerVector(twoNumber, bitWidth)
 	oneVector := powerVector(oneNumber, N)
 
 	deltaYZ := new(operation.Scalar).Sub(z, zSquare)
 	// ip1 = <1^(n*m), y^(n*m)>
-	var ip1, ip2 *operation.Scalar
-	var err error
-	if ip1, err = innerProduct(oneVector, yVector); err != nil {
-		return nil, err
-	} else if ip2, err = innerProduct(oneVectorN, twoVectorN); err != nil {
-		return nil, err
-	} else {
-		deltaYZ.Mul(deltaYZ, ip1)
-		sum := new(operation.Scalar).FromUint64(0)
-		zTmp := new(operation.Scalar).Set(zSquare)
-		for j := 0; j < int(N/privacy_util.MaxExp); j++ {
-			zTmp.Mul(zTmp, z)
-			sum.Add(sum, zTmp)
-		}
-		sum.Mul(sum, ip2)
-		deltaYZ.Sub(deltaYZ, sum)
-	}
-	return deltaYZ, nil
-}
-
-func innerProduct(a []*operation.Scalar, b []*operation.Scalar) (*operation.Scalar, error) {
-	if len(a) != len(b) {
-		return nil, fmt.Errorf("incompatible sizes of a and b")
-	}
-	result := new(operation.Scalar).FromUint64(uint64(0))
-	for i := range a {
-		// res = a[i]*b[i] + res % l
-		result.MulAdd(a[i], b[i], result)
+	ip1 := oneVector.InnerProduct(yVector)
+	ip2 := oneVectorN.InnerProduct(twoVectorN)
+	deltaYZ.Mul(deltaYZ, ip1)
+	sum := new(operation.Scalar).FromUint64(0)
+	zTmp := new(operation.Scalar).Set(zSquare)
+	for j := 0; j < N/bitWidth; j++ {
+		zTmp.Mul(zTmp, z)
+		sum.Add(sum, zTmp)
 	}
-	return result, nil
-}
-
-func vectorAdd(a []*operation.Scalar, b []*operation.Scalar) ([]*operation.Scalar, error) {
-	if len(a) != len(b) {
-		return nil, fmt.Errorf("incompatible sizes of a and b")
-	}
-	result := make([]*operation.Scalar, len(a))
-	for i := range a {
-		result[i] = new(operation.Scalar).Add(a[i], b[i])
-	}
-	return result, nil
+	sum.Mul(sum, ip2)
+	deltaYZ.Sub(deltaYZ, sum)
+	return deltaYZ
 }
 
 //nolint:gocritic // This function uses capitalized variable name
@@ -109,6 +86,9 @@ func setAggregateParams(N int) *bulletproofParams {
 	aggParam.h = AggParam.h[0:N]
 	aggParam.u = AggParam.u
 	aggParam.cs = AggParam.cs
+	aggParam.uTable = AggParam.uTable
+	aggParam.gTable = AggParam.gTable
+	aggParam.hTable = AggParam.hTable
 	return aggParam
 }
 
@@ -126,69 +106,62 @@ func roundUpPowTwo(v int) int {
 	return v
 }
 
-func hadamardProduct(a []*operation.Scalar, b []*operation.Scalar) ([]*operation.Scalar, error) {
-	if len(a) != len(b) {
-		return nil, fmt.Errorf("invalid input")
-	}
-	result := make([]*operation.Scalar, len(a))
-	for i := 0; i < len(result); i++ {
-		result[i] = new(operation.Scalar).Mul(a[i], b[i])
-	}
-	return result, nil
-}
-
 // powerVector calculates base^n
-func powerVector(base *operation.Scalar, n int) []*operation.Scalar {
-	result := make([]*operation.Scalar, n)
-	result[0] = new(operation.Scalar).FromUint64(1)
-	if n > 1 {
-		result[1] = new(operation.Scalar).Set(base)
-		for i := 2; i < n; i++ {
-			result[i] = new(operation.Scalar).Mul(result[i-1], base)
-		}
-	}
-	return result
-}
-
-// vectorAddScalar adds a vector to a big int, returns big int array
-func vectorAddScalar(v []*operation.Scalar, s *operation.Scalar) []*operation.Scalar {
-	result := make([]*operation.Scalar, len(v))
-	for i := range v {
-		result[i] = new(operation.Scalar).Add(v[i], s)
-	}
-	return result
+func powerVector(base *operation.Scalar, n int) operation.ScalarVector {
+	return operation.NewScalarVector(n).Powers(base)
 }
 
-// vectorMulScalar mul a vector to a big int, returns a vector
-func vectorMulScalar(v []*operation.Scalar, s *operation.Scalar) []*operation.Scalar {
-	result := make([]*operation.Scalar, len(v))
+// setBits writes the n lowest bits of number into v as scalars 0 / 1
+func setBits(v operation.ScalarVector, number uint64) {
 	for i := range v {
-		result[i] = new(operation.Scalar).Mul(v[i], s)
+		if number&1 == 1 {
+			v[i].Set(operation.ScOne)
+		} else {
+			v[i].Set(operation.ScZero)
+		}
+		number >>= 1
 	}
-	return result
 }
 
 // CommitAll commits a list of PCM_CAPACITY value(s)
-func encodeVectors(l []*operation.Scalar, r []*operation.Scalar, g []*operation.Point, h []*operation.Point, b *msMultBuilder) (*msMultBuilder, error) {
+func encodeVectors(l operation.ScalarVector, r operation.ScalarVector, g []*operation.Point, h []*operation.Point, b *msMultBuilder) (*msMultBuilder, error) {
 	if len(l) != len(r) || len(g) != len(l) || len(h) != len(g) {
 		return nil, fmt.Errorf("invalid input")
 	}
-	err := b.Append(l, g)
+	err := b.Append(l.Ptrs(), g)
 	if err != nil {
 		return nil, err
 	}
-	err = b.Append(r, h)
+	err = b.Append(r.Ptrs(), h)
 	if err != nil {
 		return nil, err
 	}
 	return b, nil
 }
 
+// encodeVectorsTable is encodeVectors over the precomputed tables of g and h
+func encodeVectorsTable(l operation.ScalarVector, r operation.ScalarVector, g *operation.MSMTable, h *operation.MSMTable, b *msMultBuilder) (*msMultBuilder, error) {
+	if len(l) != len(r) {
+		return nil, fmt.Errorf("invalid input")
+	}
+	if err := b.AppendTable(l.Ptrs(), g, 0); err != nil {
+		return nil, err
+	}
+	if err := b.AppendTable(r.Ptrs(), h, 0); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
 // msMultBuilder is a helper struct to make best use of MultiScalarMult functions. It assumes caller never passes "nil" scalars / points
+// Terms over precomputed generator tables are kept apart so that Execute can use their cached lookup tables.
 type msMultBuilder struct {
 	scalars []*operation.Scalar
 	points []*operation.Point
+	tableTerms []operation.MSMTableTerms
 	useVarTime bool
+	// workers is the number of goroutines Execute may use; 1 keeps it on the calling goroutine
+	workers int
 }
 
 func NewMSMultBuilder(_useVarTime bool) *msMultBuilder {
@@ -196,9 +169,16 @@ func NewMSMultBuilder(_useVarTime bool) *msMultBuilder {
 		useVarTime: _useVarTime,
 		scalars: []*operation.Scalar{},
 		points: []*operation.Point{},
+		workers: 1,
 	}
 }
 
+// SetWorkers lets Execute split the multiplication across n goroutines. A non-positive n uses GOMAXPROCS.
+func (b *msMultBuilder) SetWorkers(n int) *msMultBuilder {
+	b.workers = n
+	return b
+}
+
 func (b *msMultBuilder) Clone() *msMultBuilder {
 	scLst := make([]*operation.Scalar, len(b.scalars))
 	pLst := make([]*operation.Point, len(b.scalars))
@@ -206,10 +186,16 @@ func (b *msMultBuilder) Clone() *msMultBuilder {
 		scLst[i] = operation.NewScalar().Set(b.scalars[i])
 		pLst[i] = operation.NewGeneratorPoint().Set(b.points[i])
 	}
+	tableTerms := make([]operation.MSMTableTerms, len(b.tableTerms))
+	for i, tt := range b.tableTerms {
+		tableTerms[i] = operation.MSMTableTerms{Table: tt.Table, Offset: tt.Offset, Scalars: operation.ScalarVectorFromSlice(tt.Scalars).Ptrs()}
+	}
 	return &msMultBuilder{
 		useVarTime: b.useVarTime,
 		scalars: scLst,
 		points: pLst,
+		tableTerms: tableTerms,
+		workers: b.workers,
 	}
 }
 
@@ -226,6 +212,15 @@ func (b *msMultBuilder) AppendSingle(sc *operation.Scalar, p *operation.Point) {
 	b.Append([]*operation.Scalar{sc}, []*operation.Point{p})
 }
 
+// AppendTable adds scLst[i] * table.Point(offset+i) for every i
+func (b *msMultBuilder) AppendTable(scLst []*operation.Scalar, table *operation.MSMTable, offset int) error {
+	if offset < 0 || offset+len(scLst) > table.Len() {
+		return fmt.Errorf("msMultBuilder table terms out of range")
+	}
+	b.tableTerms = append(b.tableTerms, operation.MSMTableTerms{Table: table, Offset: offset, Scalars: scLst})
+	return nil
+}
+
 func (b *msMultBuilder) AppendWithMultiplier(scLst []*operation.Scalar, pLst []*operation.Point, n *operation.Scalar) error {
 	var newScLst []*operation.Scalar
 	for _, sc := range scLst {
@@ -234,41 +229,135 @@ func (b *msMultBuilder) AppendWithMultiplier(scLst []*operation.Scalar, pLst []*
 	return b.Append(newScLst, pLst)
 }
 
+// AppendBuilderWithMultiplier adds every term of other, table terms included, scaled by n
+func (b *msMultBuilder) AppendBuilderWithMultiplier(other *msMultBuilder, n *operation.Scalar) error {
+	if err := b.AppendWithMultiplier(other.scalars, other.points, n); err != nil {
+		return err
+	}
+	for _, tt := range other.tableTerms {
+		scaled := operation.ScalarVectorFromSlice(tt.Scalars)
+		if err := b.AppendTable(scaled.MulScalar(scaled, n).Ptrs(), tt.Table, tt.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *msMultBuilder) Execute() (result *operation.Point) {
-	if b.useVarTime {
+	switch {
+	case b.workers != 1 && b.useVarTime:
+		result = operation.NewIdentityPoint().ParallelVarTimeMultiScalarMultTables(b.tableTerms, b.scalars, b.points, b.workers)
+	case b.workers != 1:
+		result = operation.NewIdentityPoint().ParallelMultiScalarMultTables(b.tableTerms, b.scalars, b.points, b.workers)
+	case len(b.tableTerms) > 0 && b.useVarTime:
+		result = operation.NewIdentityPoint().VarTimeMultiScalarMultTables(b.tableTerms, b.scalars, b.points)
+	case len(b.tableTerms) > 0:
+		result = operation.NewIdentityPoint().MultiScalarMultTables(b.tableTerms, b.scalars, b.points)
+	case b.useVarTime:
 		result = operation.NewIdentityPoint().VarTimeMultiScalarMult(b.scalars, b.points)
-	} else {
+	default:
 		result = operation.NewIdentityPoint().MultiScalarMult(b.scalars, b.points)
 	}
 	// reset builder after finalization
-	*b = *NewMSMultBuilder(b.useVarTime)
+	*b = *NewMSMultBuilder(b.useVarTime).SetWorkers(b.workers)
 	return result
 }
 
 // bulletproofParams includes all generator for aggregated range proof
 func newBulletproofParams(m int) *bulletproofParams {
-	maxExp := privacy_util.MaxExp
-	numCommitValue := privacy_util.NumBase
-	maxOutputCoin := privacy_util.MaxOutputCoin
+	param, err := newBulletproofParamsWithDerivation(m, operation.GeneratorDerivationLegacy)
+	if err != nil {
+		panic(err)
+	}
+	param.uTable = operation.NewFixedBaseTable(param.u)
+	param.gTable = operation.NewMSMTable(param.g)
+	param.hTable = operation.NewMSMTable(param.h)
+	return param
+}
+
+// newBulletproofParamsWithDerivation derives the generators for up to m outputs under the given scheme.
+// The legacy scheme lays g, h and u out in one index space after the Pedersen generators, so it is limited to
+// MaxOutputCoin outputs; later schemes give each vector its own label, so the capacity can grow without collisions.
+func newBulletproofParamsWithDerivation(m int, version operation.GeneratorDerivation) (*bulletproofParams, error) {
+	maxExp := MaxExp
+	// the legacy layout starts after the Pedersen generators
+	numCommitValue := int(operation.PedersenRandomnessIndex) + 1
+	maxOutputCoin := MaxOutputCoin
+	if version == operation.GeneratorDerivationLegacy && m > maxOutputCoin {
+		// h starts at the g generator of output maxOutputCoin, so a larger m would reuse generators
+		return nil, fmt.Errorf("legacy generator derivation supports at most %d outputs, got %d", maxOutputCoin, m)
+	}
 	capacity := maxExp * m // fixed value
 	param := new(bulletproofParams)
 	param.g = make([]*operation.Point, capacity)
 	param.h = make([]*operation.Point, capacity)
 	csByte := []byte{}
 
+	gLabel, hLabel, uLabel := operation.CStringBulletProof, operation.CStringBulletProof, operation.CStringBulletProof
+	gOffset, hOffset, uIndex := numCommitValue, numCommitValue+maxOutputCoin*maxExp, numCommitValue+2*maxOutputCoin*maxExp
+	if version != operation.GeneratorDerivationLegacy {
+		gLabel, hLabel, uLabel = operation.CStringBPG, operation.CStringBPH, operation.CStringBPU
+		gOffset, hOffset, uIndex = 0, 0, 0
+	}
+
+	var err error
 	for i := 0; i < capacity; i++ {
-		param.g[i] = operation.HashToPointFromIndex(int64(numCommitValue+i), operation.CStringBulletProof)
-		param.h[i] = operation.HashToPointFromIndex(int64(numCommitValue+i+maxOutputCoin*maxExp), operation.CStringBulletProof)
+		if param.g[i], err = operation.DeriveGenerator(version, gLabel, uint64(gOffset+i)); err != nil {
+			return nil, err
+		}
+		if param.h[i], err = operation.DeriveGenerator(version, hLabel, uint64(hOffset+i)); err != nil {
+			return nil, err
+		}
 		csByte = append(csByte, param.g[i].ToBytesS()...)
 		csByte = append(csByte, param.h[i].ToBytesS()...)
 	}
 
-	param.u = new(operation.Point)
-	param.u = operation.HashToPointFromIndex(int64(numCommitValue+2*maxOutputCoin*maxExp), operation.CStringBulletProof)
+	if param.u, err = operation.DeriveGenerator(version, uLabel, uint64(uIndex)); err != nil {
+		return nil, err
+	}
 	csByte = append(csByte, param.u.ToBytesS()...)
 
-	param.cs = operation.HashToPoint(csByte)
-	return param
+	if version == operation.GeneratorDerivationLegacy {
+		param.cs = operation.LegacyHashToPoint(csByte)
+	} else if param.cs, err = operation.HashToCurve(csByte, []byte(operation.DSTGeneratorV1)); err != nil {
+		return nil, err
+	}
+	return param, nil
+}
+
+// WriteGeneratorSet writes the Pedersen and Bulletproof generators for up to m outputs, as derived by the given scheme,
+// so that other implementations can check them. After a "#" header line, each line is "<name> <index> <hex point>",
+// where name is one of G (Pedersen), g, h, u and cs (the transcript seed).
+func WriteGeneratorSet(w io.Writer, version operation.GeneratorDerivation, m int) error {
+	pedCom, err := operation.NewPedersenParamsWithDerivation(version)
+	if err != nil {
+		return err
+	}
+	param, err := newBulletproofParamsWithDerivation(m, version)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "# newbp generator set: derivation %d, %d outputs of %d bits\n", version, m, MaxExp); err != nil {
+		return err
+	}
+	lines := []struct {
+		name   string
+		points []*operation.Point
+	}{
+		{"G", pedCom.G},
+		{"g", param.g},
+		{"h", param.h},
+		{"u", []*operation.Point{param.u}},
+		{"cs", []*operation.Point{param.cs}},
+	}
+	for _, l := range lines {
+		for i, p := range l.points {
+			if _, err = fmt.Fprintf(w, "%s %d %x\n", l.name, i, p.ToBytesS()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func generateChallenge(hashCache []byte, values []*operation.Point) *operation.Scalar {
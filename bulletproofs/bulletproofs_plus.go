@@ -0,0 +1,606 @@
+package bulletproofs
+
+import (
+	"math"
+
+	"github.com/dat-incognito-org/newbp/operation"
+	"github.com/pkg/errors"
+)
+
+// AggregatedRangeProofPlus is a Bulletproofs+ range proof (Chung et al., https://eprint.iacr.org/2020/735).
+// It proves the same statement as AggregatedRangeProof with a weighted inner product argument, so it drops
+// S, T1, T2, tauX, tHat and the inner product proof's a, b and p in favour of A1, B, r1, s1 and d1.
+// It uses the generators of AggParam, with the Pedersen value base (or an asset tag) as the inner product base
+// and HBase as the blinding base.
+type AggregatedRangeProofPlus struct {
+	cmsValue []*operation.Point
+	a        *operation.Point
+	a1       *operation.Point
+	b        *operation.Point
+	r1       *operation.Scalar
+	s1       *operation.Scalar
+	d1       *operation.Scalar
+	l        []*operation.Point
+	r        []*operation.Point
+	// bitWidth is the number of bits the values are proven to fit in; 0 means DefaultBitWidth
+	bitWidth int
+}
+
+// transcriptDomainPlus separates Bulletproofs+ challenges from those of AggregatedRangeProof
+const transcriptDomainPlus = "newbp/bulletproofs-plus/transcript"
+
+// plusTranscriptSeed binds the generators, the value base, the bit-width and every commitment into the first challenge
+func plusTranscriptSeed(cs, base *operation.Point, bitWidth int, cmsValue []*operation.Point) []byte {
+	data := appendLengthPrefixed(nil, []byte(transcriptDomainPlus))
+	data = append(data, cs.ToBytesS()...)
+	data = append(data, base.ToBytesS()...)
+	data = append(data, byte(bitWidth), byte(len(cmsValue)))
+	for _, cm := range cmsValue {
+		data = append(data, cm.ToBytesS()...)
+	}
+	seed := operation.Keccak256(data)
+	return seed[:]
+}
+
+// valueBaseOrDefault returns the Pedersen value base when no asset tag is given
+func valueBaseOrDefault(base *operation.Point) *operation.Point {
+	if base == nil {
+		return operation.PedCom.G[operation.PedersenValueIndex]
+	}
+	return base
+}
+
+// weightedInnerProduct returns sum a[i] * b[i] * y^(i+1), given yPowers[i] = y^i
+func weightedInnerProduct(a, b, yPowers operation.ScalarVector) *operation.Scalar {
+	result := new(operation.Scalar).FromUint64(0)
+	tmp := new(operation.Scalar)
+	for i := range a {
+		tmp.Mul(&a[i], &b[i])
+		result.MulAdd(tmp, &yPowers[i+1], result)
+	}
+	return result
+}
+
+// BitWidth returns the number of bits the proof's values are proven to fit in
+func (proof AggregatedRangeProofPlus) BitWidth() int {
+	return normalizeBitWidth(proof.bitWidth)
+}
+
+// dimensions is AggregatedRangeProof.dimensions for Bulletproofs+
+func (proof AggregatedRangeProofPlus) dimensions() (bitWidth, numValuePad, N int, err error) {
+	numValue := len(proof.cmsValue)
+	if numValue > MaxOutputCoin {
+		return 0, 0, 0, errors.New("Must less than MaxOutputNumber")
+	}
+	bitWidth = proof.BitWidth()
+	if !ValidBitWidth(bitWidth) {
+		return 0, 0, 0, errors.Errorf("invalid range proof bit-width %d", bitWidth)
+	}
+	numValuePad = roundUpPowTwo(numValue)
+	N = bitWidth * numValuePad
+	if len(proof.l) != int(math.Log2(float64(N))) || len(proof.r) != len(proof.l) {
+		return 0, 0, 0, errors.Errorf("weighted inner product argument does not match a %d-bit range proof for %d outputs", bitWidth, numValue)
+	}
+	return bitWidth, numValuePad, N, nil
+}
+
+// IsNil returns true if any field in this proof is nil
+func (proof AggregatedRangeProofPlus) IsNil() bool {
+	return proof.a == nil || proof.a1 == nil || proof.b == nil || proof.r1 == nil || proof.s1 == nil || proof.d1 == nil
+}
+
+// ValidateSanity performs sanity checks for this proof.
+// All points must lie in the prime-order subgroup; A, A1 and B must not be the identity.
+func (proof AggregatedRangeProofPlus) ValidateSanity() bool {
+	if proof.IsNil() {
+		return false
+	}
+	for _, cm := range proof.cmsValue {
+		if !cm.PointValid() {
+			return false
+		}
+	}
+	if !proof.a.PointValidNonIdentity() || !proof.a1.PointValidNonIdentity() || !proof.b.PointValidNonIdentity() {
+		return false
+	}
+	if len(proof.l) != len(proof.r) {
+		return false
+	}
+	for i := range proof.l {
+		if !proof.l[i].PointValid() || !proof.r[i].PointValid() {
+			return false
+		}
+	}
+	return true
+}
+
+func (proof AggregatedRangeProofPlus) GetCommitments() []*operation.Point { return proof.cmsValue }
+
+func (proof *AggregatedRangeProofPlus) SetCommitments(cmsValue []*operation.Point) {
+	proof.cmsValue = cmsValue
+}
+
+// Bytes encodes the proof as [bitWidthFlag | bitWidth] count || cmsValue || A || A1 || B || r1 || s1 || d1 || len(L) || L || R,
+// where the width byte is only present for widths other than DefaultBitWidth, as in AggregatedRangeProof.Bytes
+func (proof AggregatedRangeProofPlus) Bytes() []byte {
+	// the output count byte must stay below bitWidthFlag, or it would read as a width byte
+	if proof.IsNil() || len(proof.cmsValue) >= bitWidthFlag {
+		return []byte{}
+	}
+	var res []byte
+	if bitWidth := proof.BitWidth(); bitWidth != DefaultBitWidth {
+		res = append(res, byte(bitWidthFlag|bitWidth))
+	}
+	res = append(res, byte(len(proof.cmsValue)))
+	for _, cm := range proof.cmsValue {
+		res = append(res, cm.ToBytesS()...)
+	}
+	res = append(res, proof.a.ToBytesS()...)
+	res = append(res, proof.a1.ToBytesS()...)
+	res = append(res, proof.b.ToBytesS()...)
+	res = append(res, proof.r1.ToBytesS()...)
+	res = append(res, proof.s1.ToBytesS()...)
+	res = append(res, proof.d1.ToBytesS()...)
+	res = append(res, byte(len(proof.l)))
+	for _, l := range proof.l {
+		res = append(res, l.ToBytesS()...)
+	}
+	for _, r := range proof.r {
+		res = append(res, r.ToBytesS()...)
+	}
+	return res
+}
+
+// SetBytes decodes a proof made by Bytes. Like AggregatedRangeProof.SetBytes, it rejects empty input and trailing bytes.
+func (proof *AggregatedRangeProofPlus) SetBytes(bytes []byte) error {
+	errInvalid := errors.New("Range Proof Plus unmarshaling from bytes failed")
+	if len(bytes) == 0 {
+		return errInvalid
+	}
+	result := AggregatedRangeProofPlus{bitWidth: DefaultBitWidth}
+	offset := 0
+	if bytes[0]&bitWidthFlag != 0 {
+		result.bitWidth = int(bytes[0] &^ bitWidthFlag)
+		if result.bitWidth == DefaultBitWidth || !ValidBitWidth(result.bitWidth) {
+			return errors.New("Range Proof Plus unmarshaling from bytes failed: invalid bit-width")
+		}
+		offset++
+	}
+	readByte := func() (int, error) {
+		if offset >= len(bytes) {
+			return 0, errInvalid
+		}
+		offset++
+		return int(bytes[offset-1]), nil
+	}
+	readPoint := func() (*operation.Point, error) {
+		if offset+operation.Ed25519KeySize > len(bytes) {
+			return nil, errInvalid
+		}
+		offset += operation.Ed25519KeySize
+		return new(operation.Point).FromBytesSStrict(bytes[offset-operation.Ed25519KeySize : offset])
+	}
+	readScalar := func() (*operation.Scalar, error) {
+		if offset+operation.Ed25519KeySize > len(bytes) {
+			return nil, errInvalid
+		}
+		offset += operation.Ed25519KeySize
+		return new(operation.Scalar).FromBytesSStrict(bytes[offset-operation.Ed25519KeySize : offset])
+	}
+	readPoints := func(n int) ([]*operation.Point, error) {
+		points := make([]*operation.Point, n)
+		var err error
+		for i := range points {
+			if points[i], err = readPoint(); err != nil {
+				return nil, err
+			}
+		}
+		return points, nil
+	}
+
+	lenValues, err := readByte()
+	if err != nil {
+		return err
+	}
+	if lenValues >= bitWidthFlag {
+		return errInvalid
+	}
+	if result.cmsValue, err = readPoints(lenValues); err != nil {
+		return err
+	}
+	for _, p := range []**operation.Point{&result.a, &result.a1, &result.b} {
+		if *p, err = readPoint(); err != nil {
+			return err
+		}
+	}
+	for _, sc := range []**operation.Scalar{&result.r1, &result.s1, &result.d1} {
+		if *sc, err = readScalar(); err != nil {
+			return err
+		}
+	}
+	lenL, err := readByte()
+	if err != nil {
+		return err
+	}
+	if result.l, err = readPoints(lenL); err != nil {
+		return err
+	}
+	if result.r, err = readPoints(lenL); err != nil {
+		return err
+	}
+	if offset != len(bytes) {
+		return errInvalid
+	}
+	*proof = result
+	return nil
+}
+
+// ProvePlus creates a Bulletproofs+ range proof for the witness, at the witness's bit-width
+func (wit AggregatedRangeWitness) ProvePlus() (*AggregatedRangeProofPlus, error) {
+	return wit.provePlus(nil, nil)
+}
+
+// ProvePlusWithRand is ProvePlus with the blinding values drawn from r, like ProveWithRand
+func (wit AggregatedRangeWitness) ProvePlusWithRand(r operation.RandomSource) (*AggregatedRangeProofPlus, error) {
+	return wit.provePlus(nil, r)
+}
+
+// ProvePlusUsingBase is ProvePlus with anAssetTag as the value base of the commitments, like ProveUsingBase
+func (wit AggregatedRangeWitness) ProvePlusUsingBase(anAssetTag *operation.Point) (*AggregatedRangeProofPlus, error) {
+	if anAssetTag == nil {
+		return nil, errors.New("asset tag is nil")
+	}
+	return wit.provePlus(anAssetTag, nil)
+}
+
+// ProvePlusUsingBaseWithRand is ProvePlusUsingBase with the blinding values drawn from r
+func (wit AggregatedRangeWitness) ProvePlusUsingBaseWithRand(anAssetTag *operation.Point, r operation.RandomSource) (*AggregatedRangeProofPlus, error) {
+	if anAssetTag == nil {
+		return nil, errors.New("asset tag is nil")
+	}
+	return wit.provePlus(anAssetTag, r)
+}
+
+// provePlus creates the proof with base as the value base; a nil base is the Pedersen value base
+func (wit AggregatedRangeWitness) provePlus(base *operation.Point, r operation.RandomSource) (*AggregatedRangeProofPlus, error) {
+	proof := new(AggregatedRangeProofPlus)
+	numValue := len(wit.values)
+	if numValue > MaxOutputCoin {
+		return nil, errors.New("Must less than MaxOutputCoin")
+	}
+	maxExp, err := wit.checkBitWidth()
+	if err != nil {
+		return nil, err
+	}
+	proof.bitWidth = maxExp
+	numValuePad := roundUpPowTwo(numValue)
+	N := maxExp * numValuePad
+	aggParam := setAggregateParams(N)
+
+	proof.cmsValue = make([]*operation.Point, numValue)
+	for i := 0; i < numValue; i++ {
+		value := new(operation.Scalar).FromUint64(wit.values[i])
+		if base == nil {
+			proof.cmsValue[i] = operation.PedCom.CommitAtIndex(value, wit.rands[i], operation.PedersenValueIndex)
+		} else {
+			proof.cmsValue[i] = new(operation.Point).AddPedersen(value, base, wit.rands[i], operation.HBase)
+		}
+	}
+	base = valueBaseOrDefault(base)
+
+	// A = h^alpha * G^aL * H^aR
+	aL := operation.NewScalarVector(N)
+	for i := 0; i < numValue; i++ {
+		setBits(aL[i*maxExp:(i+1)*maxExp], wit.values[i])
+	}
+	aR := operation.NewScalarVector(N).AddScalar(aL, operation.ScMinusOne)
+	alpha, err := operation.RandomScalarFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	msmBuilder := NewMSMultBuilder(false)
+	if _, err = encodeVectorsTable(aL, aR, aggParam.gTable, aggParam.hTable, msmBuilder); err != nil {
+		return nil, err
+	}
+	msmBuilder.AppendSingle(alpha, operation.HBase)
+	proof.a = msmBuilder.Execute()
+
+	// challenge y, z
+	y := generateChallenge(plusTranscriptSeed(aggParam.cs, base, maxExp, proof.cmsValue), []*operation.Point{proof.a})
+	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a})
+	yPowers := powerVector(y, N+2)
+
+	// aL1 = aL - z*1^N, aR1 = aR + z*1^N + d hada y^(N-i), where d[j*n+i] = z^(2(j+1)) * 2^i;
+	// the commitments enter with weight y^(N+1) * z^(2(j+1)), so alpha1 = alpha + y^(N+1) * sum z^(2(j+1)) * rand_j
+	zSquare := new(operation.Scalar).Mul(z, z)
+	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), maxExp)
+	d := operation.NewScalarVector(N)
+	alpha1 := new(operation.Scalar).Set(alpha)
+	zTmp := new(operation.Scalar).Set(zSquare)
+	for j := 0; j < numValuePad; j++ {
+		d[j*maxExp:(j+1)*maxExp].MulScalar(twoVectorN, zTmp)
+		if j < numValue {
+			alpha1.Add(alpha1, new(operation.Scalar).Mul(new(operation.Scalar).Mul(zTmp, &yPowers[N+1]), wit.rands[j]))
+		}
+		zTmp.Mul(zTmp, zSquare)
+	}
+	a := aL.AddScalar(aL, new(operation.Scalar).Sub(operation.ScZero, z))
+	b := aR.AddScalar(aR, z)
+	for i := range b {
+		b[i].MulAdd(&d[i], &yPowers[N-i], &b[i])
+	}
+
+	// weighted inner product argument for <a, b>_y
+	G := operation.PointVectorFromSlice(aggParam.g)
+	H := operation.PointVectorFromSlice(aggParam.h)
+	yInversePowers := powerVector(new(operation.Scalar).Invert(y), N)
+	hashCache := z.ToBytesS()
+	for n := N; n > 1; {
+		n /= 2
+		aLo, aHi, bLo, bHi := a[:n], a[n:], b[:n], b[n:]
+		dL, err := operation.RandomScalarFrom(r)
+		if err != nil {
+			return nil, err
+		}
+		dR, err := operation.RandomScalarFrom(r)
+		if err != nil {
+			return nil, err
+		}
+		// L = G_hi^(aLo * y^-n) * H_lo^bHi * base^cL * h^dL, R = G_lo^(aHi * y^n) * H_hi^bLo * base^cR * h^dR
+		aLoScaled := operation.NewScalarVector(n).MulScalar(aLo, &yInversePowers[n])
+		aHiScaled := operation.NewScalarVector(n).MulScalar(aHi, &yPowers[n])
+		cL := weightedInnerProduct(aLo, bHi, yPowers)
+		cR := weightedInnerProduct(aHiScaled, bLo, yPowers)
+		if _, err = encodeVectors(aLoScaled, bHi, G[n:].Ptrs(), H[:n].Ptrs(), msmBuilder); err != nil {
+			return nil, err
+		}
+		msmBuilder.Append([]*operation.Scalar{cL, dL}, []*operation.Point{base, operation.HBase})
+		L := msmBuilder.Execute()
+		if _, err = encodeVectors(aHiScaled, bLo, G[:n].Ptrs(), H[n:].Ptrs(), msmBuilder); err != nil {
+			return nil, err
+		}
+		msmBuilder.Append([]*operation.Scalar{cR, dR}, []*operation.Point{base, operation.HBase})
+		R := msmBuilder.Execute()
+		proof.l = append(proof.l, L)
+		proof.r = append(proof.r, R)
+
+		e := generateChallenge(hashCache, []*operation.Point{L, R})
+		hashCache = e.ToBytesS()
+		eInverse := new(operation.Scalar).Invert(e)
+		eSquare := new(operation.Scalar).Mul(e, e)
+		eInverseSquare := new(operation.Scalar).Mul(eInverse, eInverse)
+
+		// G' = G_lo^(e^-1) * G_hi^(e * y^-n), H' = H_lo^e * H_hi^(e^-1)
+		// a' = aLo * e + aHi * y^n * e^-1, b' = bLo * e^-1 + bHi * e
+		G = G[:n].Fold(G[:n], G[n:], eInverse, new(operation.Scalar).Mul(e, &yInversePowers[n]))
+		H = H[:n].Fold(H[:n], H[n:], e, eInverse)
+		a = aLo.Fold(aLo, aHi, e, new(operation.Scalar).Mul(eInverse, &yPowers[n]))
+		b = bLo.Fold(bLo, bHi, eInverse, e)
+		alpha1.Add(alpha1, new(operation.Scalar).Mul(dL, eSquare))
+		alpha1.Add(alpha1, new(operation.Scalar).Mul(dR, eInverseSquare))
+	}
+
+	// final round: A1 = G^rr * H^ss * base^(rr*y*b + ss*y*a) * h^delta, B = base^(rr*y*ss) * h^eta
+	var rr, ss, delta, eta *operation.Scalar
+	for _, sc := range []**operation.Scalar{&rr, &ss, &delta, &eta} {
+		if *sc, err = operation.RandomScalarFrom(r); err != nil {
+			return nil, err
+		}
+	}
+	ry := new(operation.Scalar).Mul(rr, y)
+	baseScalar := new(operation.Scalar).Mul(ry, &b[0])
+	baseScalar.MulAdd(new(operation.Scalar).Mul(ss, y), &a[0], baseScalar)
+	msmBuilder.Append([]*operation.Scalar{rr, ss, baseScalar, delta}, []*operation.Point{&G[0], &H[0], base, operation.HBase})
+	proof.a1 = msmBuilder.Execute()
+	msmBuilder.Append([]*operation.Scalar{new(operation.Scalar).Mul(ry, ss), eta}, []*operation.Point{base, operation.HBase})
+	proof.b = msmBuilder.Execute()
+
+	e := generateChallenge(hashCache, []*operation.Point{proof.a1, proof.b})
+	proof.r1 = new(operation.Scalar).MulAdd(&a[0], e, rr)
+	proof.s1 = new(operation.Scalar).MulAdd(&b[0], e, ss)
+	proof.d1 = new(operation.Scalar).MulAdd(delta, e, eta)
+	proof.d1.MulAdd(alpha1, new(operation.Scalar).Mul(e, e), proof.d1)
+	return proof, nil
+}
+
+// plusVerifier accumulates the verification equations of Bulletproofs+ proofs into one multi-scalar multiplication.
+// The scalars of the shared generators g, h and HBase are summed across proofs.
+type plusVerifier struct {
+	gScalars    operation.ScalarVector
+	hScalars    operation.ScalarVector
+	hBaseScalar *operation.Scalar
+	builder     *msMultBuilder
+}
+
+func newPlusVerifier() *plusVerifier {
+	return &plusVerifier{
+		gScalars:    operation.NewScalarVector(0),
+		hScalars:    operation.NewScalarVector(0),
+		hBaseScalar: new(operation.Scalar).FromUint64(0),
+		builder:     NewMSMultBuilder(true).SetWorkers(MSMWorkers),
+	}
+}
+
+// add appends weight times the verification equation of proof, with base as the value base. Every term of the equation
+// sums to the identity for a valid proof:
+//
+//	e^2 * (A_hat + sum e_k^2 L_k + e_k^-2 R_k) + e * A1 + B = G^(r1*e*gs) * H^(s1*e*hs) * base^(r1*y*s1) * h^d1
+//
+// where A_hat = A * g^(-z) * H^(z + d hada y^(N-i)) * V^(y^(N+1) * z^(2(j+1))) * base^((z-z^2) * sum y^i - z * y^(N+1) * sum d)
+// is the statement of the weighted inner product argument, and gs, hs fold the round challenges into the generators.
+func (v *plusVerifier) add(proof *AggregatedRangeProofPlus, base *operation.Point, weight *operation.Scalar) error {
+	if proof == nil || proof.IsNil() {
+		return errors.New("range proof plus is incomplete")
+	}
+	maxExp, numValuePad, N, err := proof.dimensions()
+	if err != nil {
+		return err
+	}
+	aggParam := setAggregateParams(N)
+
+	y := generateChallenge(plusTranscriptSeed(aggParam.cs, base, maxExp, proof.cmsValue), []*operation.Point{proof.a})
+	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a})
+	challenges := innerProductChallenges(z.ToBytesS(), proof.l, proof.r)
+	hashCache := z.ToBytesS()
+	if len(challenges) > 0 {
+		hashCache = challenges[len(challenges)-1].ToBytesS()
+	}
+	e := generateChallenge(hashCache, []*operation.Point{proof.a1, proof.b})
+	inverses, err := batchInverse(append([]*operation.Scalar{y}, challenges...))
+	if err != nil {
+		return err
+	}
+	yInverse, challengeInverses := inverses[0], inverses[1:]
+
+	yPowers := powerVector(y, N+2)
+	eSquare := new(operation.Scalar).Mul(e, e)
+	wE2 := new(operation.Scalar).Mul(weight, eSquare)
+	zSquare := new(operation.Scalar).Mul(z, z)
+
+	// gs[i] = y^-i * prod (bit k of i ? e_k : e_k^-1), hs[i] = prod (bit k of i ? e_k^-1 : e_k)
+	gs := operation.NewScalarVector(N).Fill(operation.ScOne)
+	hs := operation.NewScalarVector(N).Fill(operation.ScOne)
+	logN := len(proof.l)
+	for k := range challenges {
+		for i := 0; i < N; i++ {
+			if i&(1<<uint(logN-k-1)) != 0 {
+				gs[i].Mul(&gs[i], challenges[k])
+				hs[i].Mul(&hs[i], challengeInverses[k])
+			} else {
+				gs[i].Mul(&gs[i], challengeInverses[k])
+				hs[i].Mul(&hs[i], challenges[k])
+			}
+		}
+	}
+	gs.Hadamard(gs, prepareHPrime(yInverse, N))
+
+	// the generators: g^(-w*(e^2*z + r1*e*gs)), h^(w*(e^2*(z + d*y^(N-i)) - s1*e*hs))
+	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), maxExp)
+	minusWR1E := new(operation.Scalar).Sub(operation.ScZero, new(operation.Scalar).Mul(weight, new(operation.Scalar).Mul(proof.r1, e)))
+	minusWS1E := new(operation.Scalar).Sub(operation.ScZero, new(operation.Scalar).Mul(weight, new(operation.Scalar).Mul(proof.s1, e)))
+	wE2Z := new(operation.Scalar).Mul(wE2, z)
+	minusWE2Z := new(operation.Scalar).Sub(operation.ScZero, wE2Z)
+	v.grow(N)
+	zExp := new(operation.Scalar).Set(zSquare)
+	sumZExp := new(operation.Scalar).FromUint64(0)
+	dWeight := new(operation.Scalar)
+	for j := 0; j < numValuePad; j++ {
+		sumZExp.Add(sumZExp, zExp)
+		for i := 0; i < maxExp; i++ {
+			idx := j*maxExp + i
+			// d[idx] * y^(N-idx) * w * e^2
+			dWeight.Mul(zExp, &twoVectorN[i])
+			dWeight.Mul(dWeight, &yPowers[N-idx])
+			dWeight.MulAdd(dWeight, wE2, wE2Z)
+			dWeight.MulAdd(minusWS1E, &hs[idx], dWeight)
+			v.hScalars[idx].Add(&v.hScalars[idx], dWeight)
+
+			gs[idx].MulAdd(minusWR1E, &gs[idx], minusWE2Z)
+			v.gScalars[idx].Add(&v.gScalars[idx], &gs[idx])
+		}
+		if j < len(proof.cmsValue) {
+			// V_j^(w * e^2 * y^(N+1) * z^(2(j+1)))
+			vScalar := new(operation.Scalar).Mul(wE2, &yPowers[N+1])
+			v.builder.AppendSingle(vScalar.Mul(vScalar, zExp), proof.cmsValue[j])
+		}
+		zExp.Mul(zExp, zSquare)
+	}
+
+	// base^(w * (e^2 * ((z - z^2) * sum_{i=1..N} y^i - z * y^(N+1) * sum d) - r1*y*s1))
+	sumY := new(operation.Scalar).FromUint64(0)
+	for i := 1; i <= N; i++ {
+		sumY.Add(sumY, &yPowers[i])
+	}
+	// sum d = (2^n - 1) * sum z^(2(j+1))
+	sumD := new(operation.Scalar).FromUint64(0)
+	for i := range twoVectorN {
+		sumD.Add(sumD, &twoVectorN[i])
+	}
+	sumD.Mul(sumD, sumZExp)
+	baseScalar := new(operation.Scalar).Mul(new(operation.Scalar).Sub(z, zSquare), sumY)
+	zYN1 := new(operation.Scalar).Mul(z, &yPowers[N+1])
+	baseScalar.Sub(baseScalar, zYN1.Mul(zYN1, sumD))
+	baseScalar.Mul(baseScalar, eSquare)
+	r1ys1 := new(operation.Scalar).Mul(proof.r1, y)
+	baseScalar.Sub(baseScalar, r1ys1.Mul(r1ys1, proof.s1))
+	v.builder.AppendSingle(baseScalar.Mul(baseScalar, weight), base)
+
+	// A^(w*e^2) * A1^(w*e) * B^w * h^(-w*d1) * L^(w*e^2*e_k^2) * R^(w*e^2*e_k^-2)
+	v.builder.Append([]*operation.Scalar{wE2, new(operation.Scalar).Mul(weight, e), weight}, []*operation.Point{proof.a, proof.a1, proof.b})
+	v.hBaseScalar.Sub(v.hBaseScalar, new(operation.Scalar).Mul(weight, proof.d1))
+	for k := range proof.l {
+		eK := new(operation.Scalar).Mul(challenges[k], challenges[k])
+		eKInverse := new(operation.Scalar).Mul(challengeInverses[k], challengeInverses[k])
+		v.builder.Append([]*operation.Scalar{eK.Mul(eK, wE2), eKInverse.Mul(eKInverse, wE2)}, []*operation.Point{proof.l[k], proof.r[k]})
+	}
+	return nil
+}
+
+// grow extends the shared generator scalars to n entries
+func (v *plusVerifier) grow(n int) {
+	if n > len(v.gScalars) {
+		v.gScalars = append(v.gScalars, operation.NewScalarVector(n-len(v.gScalars))...)
+		v.hScalars = append(v.hScalars, operation.NewScalarVector(n-len(v.hScalars))...)
+	}
+}
+
+// check reports whether the accumulated equations hold
+func (v *plusVerifier) check() bool {
+	v.builder.AppendSingle(v.hBaseScalar, operation.HBase)
+	if len(v.gScalars) > 0 {
+		v.builder.AppendTable(v.gScalars.Ptrs(), AggParam.gTable, 0)
+		v.builder.AppendTable(v.hScalars.Ptrs(), AggParam.hTable, 0)
+	}
+	return v.builder.Execute().IsIdentity()
+}
+
+// Verify does verification for this Bulletproofs+ proof
+func (proof AggregatedRangeProofPlus) Verify() (bool, error) {
+	return proof.verifyUsingBase(valueBaseOrDefault(nil))
+}
+
+// VerifyUsingBase verifies a proof made by ProvePlusUsingBase with the same asset tag
+func (proof AggregatedRangeProofPlus) VerifyUsingBase(anAssetTag *operation.Point) (bool, error) {
+	if anAssetTag == nil {
+		return false, errors.New("asset tag is nil")
+	}
+	return proof.verifyUsingBase(anAssetTag)
+}
+
+func (proof AggregatedRangeProofPlus) verifyUsingBase(base *operation.Point) (bool, error) {
+	v := newPlusVerifier()
+	if err := v.add(&proof, base, new(operation.Scalar).FromUint64(1)); err != nil {
+		return false, err
+	}
+	if !v.check() {
+		Logger.Log.Errorf("verify aggregated range proof plus failed")
+		return false, errors.New("verify aggregated range proof plus failed")
+	}
+	return true, nil
+}
+
+// VerifyBatchPlus verifies a list of Bulletproofs+ proofs with a single multi-scalar multiplication, each
+// proof's equation weighted by a random scalar. Like VerifyBatch, the index is that of the first malformed proof, or -1.
+func VerifyBatchPlus(proofs []*AggregatedRangeProofPlus) (bool, error, int) {
+	v := newPlusVerifier()
+	base := valueBaseOrDefault(nil)
+	for k, proof := range proofs {
+		if err := v.add(proof, base, operation.RandomScalar()); err != nil {
+			return false, err, k
+		}
+	}
+	if !v.check() {
+		Logger.Log.Errorf("batch verify aggregated range proof plus failed")
+		return false, errors.New("batch verify aggregated range proof plus failed"), -1
+	}
+	return true, nil, -1
+}
+
+// EstimateMultiRangeProofPlusSize returns the size of a Bulletproofs+ proof for nOutput outputs of the given bit-width
+func EstimateMultiRangeProofPlusSize(nOutput int, bitWidth int) uint64 {
+	size := uint64((nOutput+2*int(math.Log2(float64(bitWidth*roundUpPowTwo(nOutput))))+6)*operation.Ed25519KeySize + 2)
+	if bitWidth != DefaultBitWidth {
+		size++
+	}
+	return size
+}
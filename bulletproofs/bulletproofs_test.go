@@ -1,9 +1,18 @@
 package bulletproofs
 
 import (
+	"bytes"
+	"context"
 	crypto_rand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math/big"
 	"math/rand"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/dat-incognito-org/newbp/operation"
@@ -21,6 +30,9 @@ var _ = func() (_ struct{}) {
 
 var rangeProof1 *AggregatedRangeProof
 var rangeProof2 *bulletproofs_old.AggregatedRangeProof
+var rangeProofPlus *AggregatedRangeProofPlus
+var dalekProofBytes []byte
+var dalekCommitments []*operation.Point
 
 type fnProve = func(values []uint64, rands []*operation.Scalar, rands2 []*operation_old.Scalar)
 
@@ -43,13 +55,22 @@ var provers = map[string]fnProve{
 		}
 		rangeProof1 = proof
 	},
+	"Go-bulletproofs-plus": func(values []uint64, rands []*operation.Scalar, rands2 []*operation_old.Scalar) {
+		wit := new(AggregatedRangeWitness)
+		wit.Set(values, rands)
+		proof, err := wit.ProvePlus()
+		if err != nil {
+			panic(err)
+		}
+		rangeProofPlus = proof
+	},
 	"Dalek-cgo": func(values []uint64, rands []*operation.Scalar, rands2 []*operation_old.Scalar) {
 		randsRaw := make([][32]byte, len(rands))
 		for i, val := range rands {
 			copy(randsRaw[i][:], val.ToBytesS())
 		}
 		// ignore scalar marshaling time
-		ExtProve(values, randsRaw)
+		dalekProofBytes = ExtProve(values, randsRaw)
 	},
 }
 
@@ -68,6 +89,22 @@ var pverifiers = map[string]fnProveVerify{
 			panic(err)
 		}
 	},
+	"Go-bulletproofs-plus": func() {
+		valid, err := rangeProofPlus.Verify()
+		if !valid || err != nil {
+			panic(err)
+		}
+	},
+	"Dalek-cgo": func() {
+		proof := new(DalekRangeProof)
+		if err := proof.SetBytes(dalekProofBytes); err != nil {
+			panic(err)
+		}
+		valid, err := proof.Verify(DalekParam(), DalekTranscriptLabel, dalekCommitments, DefaultBitWidth)
+		if !valid || err != nil {
+			panic(err)
+		}
+	},
 }
 
 type fnRandomScalarMult = func()
@@ -167,6 +204,11 @@ var pedAdds = map[string]fnRandomAddPedersen{
 		pB.FromBytesS(raw_B)
 		(&operation.Point{}).AddPedersen(sc_a, pA, sc_b, pB)
 	},
+	"new-precomputed": func(_, _ []byte) {
+		sc_a := operation.RandomScalar()
+		sc_b := operation.RandomScalar()
+		operation.PedCom.CommitAtIndex(sc_a, sc_b, operation.PedersenValueIndex)
+	},
 }
 
 func BenchmarkBPProve(b *testing.B) {
@@ -184,6 +226,11 @@ func BenchmarkBPProve(b *testing.B) {
 		{"Go&new-curve-impl", 4},
 		{"Go&new-curve-impl", 8},
 		{"Go&new-curve-impl", 16},
+		{"Go-bulletproofs-plus", 1},
+		{"Go-bulletproofs-plus", 2},
+		{"Go-bulletproofs-plus", 4},
+		{"Go-bulletproofs-plus", 8},
+		{"Go-bulletproofs-plus", 16},
 		{"Dalek-cgo", 1},
 		{"Dalek-cgo", 2},
 		{"Dalek-cgo", 4},
@@ -228,6 +275,18 @@ func BenchmarkBPVerify(b *testing.B) {
 		{"Go&new-curve-impl", 8},
 		{"Go&new-curve-impl", 16},
 		{"Go&new-curve-impl", 32},
+		{"Go-bulletproofs-plus", 1},
+		{"Go-bulletproofs-plus", 2},
+		{"Go-bulletproofs-plus", 4},
+		{"Go-bulletproofs-plus", 8},
+		{"Go-bulletproofs-plus", 16},
+		{"Go-bulletproofs-plus", 32},
+		{"Dalek-cgo", 1},
+		{"Dalek-cgo", 2},
+		{"Dalek-cgo", 4},
+		{"Dalek-cgo", 8},
+		{"Dalek-cgo", 16},
+		{"Dalek-cgo", 32},
 	}
 
 	for _, bm := range benchmarks {
@@ -242,9 +301,18 @@ func BenchmarkBPVerify(b *testing.B) {
 		}
 		provers["Go&old-curve-impl"](values, rands, rands2)
 		provers["Go&new-curve-impl"](values, rands, rands2)
+		provers["Go-bulletproofs-plus"](values, rands, rands2)
+		provers["Dalek-cgo"](values, rands, rands2)
+		dalekCommitments = make([]*operation.Point, len(values))
+		for i := range values {
+			dalekCommitments[i] = DalekParam().Commit(values[i], rands[i])
+		}
 
 		b.ResetTimer()
 		b.Run(fmt.Sprintf("%s verify %d outputs", bm.prover, bm.numOutputs), func(b *testing.B) {
+			if bm.prover == "Dalek-cgo" && len(dalekProofBytes) == 0 {
+				b.Skip("libnewbp returned no proof")
+			}
 			for i := 0; i < b.N; i++ {
 				pverifiers[bm.prover]()
 			}
@@ -317,6 +385,7 @@ func BenchmarkAddPedersen(b *testing.B) {
 	}{
 		{"old"},
 		{"new"},
+		{"new-precomputed"},
 	}
 
 	for _, bm := range benchmarks {
@@ -412,3 +481,1238 @@ func TestProveVerifyRangeProof(t *testing.T) {
 		True(t, valid)
 	}
 }
+
+func TestValidateSanityRejectsTorsion(t *testing.T) {
+	values := []uint64{1, 2}
+	rands := []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()}
+	wit := new(AggregatedRangeWitness)
+	wit.Set(values, rands)
+	proof, err := wit.Prove()
+	Nil(t, err)
+	True(t, proof.ValidateSanity())
+
+	// (0, -1) is the point of order 2
+	var order2 [32]byte
+	order2[0] = 0xec
+	for i := 1; i < 31; i++ {
+		order2[i] = 0xff
+	}
+	order2[31] = 0x7f
+	torsion, err := new(operation.Point).FromBytesS(order2[:])
+	Nil(t, err)
+
+	tampered := *proof
+	tampered.a = new(operation.Point).Add(proof.a, torsion)
+	False(t, tampered.ValidateSanity())
+	err = new(AggregatedRangeProof).SetBytes(tampered.Bytes())
+	NotNil(t, err)
+
+	tampered = *proof
+	tampered.t1 = operation.NewIdentityPoint()
+	False(t, tampered.ValidateSanity())
+
+	proofAgain := new(AggregatedRangeProof)
+	Nil(t, proofAgain.SetBytes(proof.Bytes()))
+	True(t, proofAgain.ValidateSanity())
+}
+
+func TestSetBytesRejectsNonCanonicalScalar(t *testing.T) {
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{7}, []*operation.Scalar{operation.RandomScalar()})
+	proof, err := wit.Prove()
+	Nil(t, err)
+	raw := proof.Bytes()
+
+	// replace tHat with tHat + l, which encodes the same residue non-canonically
+	l, _ := new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+	offset := 1 + 5*operation.Ed25519KeySize + operation.Ed25519KeySize
+	tHatBE := make([]byte, operation.Ed25519KeySize)
+	for i := range tHatBE {
+		tHatBE[i] = raw[offset+operation.Ed25519KeySize-1-i]
+	}
+	shifted := new(big.Int).Add(new(big.Int).SetBytes(tHatBE), l).FillBytes(make([]byte, operation.Ed25519KeySize))
+	tampered := append([]byte{}, raw...)
+	for i := range shifted {
+		tampered[offset+i] = shifted[operation.Ed25519KeySize-1-i]
+	}
+	NotNil(t, new(AggregatedRangeProof).SetBytes(tampered))
+	Nil(t, new(AggregatedRangeProof).SetBytes(raw))
+}
+
+func TestLegacyHashToPointMatchesOld(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		b := make([]byte, rand.Intn(80))
+		crypto_rand.Read(b)
+		Equal(t, operation_old.HashToPoint(b).ToBytesS(), operation.LegacyHashToPoint(b).ToBytesS())
+		Equal(t, operation_old.HashToScalar(b).ToBytesS(), operation.HashToScalar(b).ToBytesS())
+	}
+	for i := 0; i < len(operation.PedCom.G); i++ {
+		Equal(t, operation_old.PedCom.G[i].ToBytesS(), operation.PedCom.G[i].ToBytesS())
+	}
+}
+
+func TestGeneratorDerivation(t *testing.T) {
+	legacy, err := newBulletproofParamsWithDerivation(MaxOutputCoin, operation.GeneratorDerivationLegacy)
+	Nil(t, err)
+	Equal(t, AggParam.cs.ToBytesS(), legacy.cs.ToBytesS())
+	// beyond MaxOutputCoin outputs the legacy g and h vectors would overlap
+	_, err = newBulletproofParamsWithDerivation(MaxOutputCoin+1, operation.GeneratorDerivationLegacy)
+	NotNil(t, err)
+	_, err = NewRangeProofParamsWithDerivation(operation.GeneratorDerivationLegacy, MaxOutputCoin+1)
+	NotNil(t, err)
+	NotNil(t, WriteGeneratorSet(new(bytes.Buffer), operation.GeneratorDerivationLegacy, MaxOutputCoin+1))
+
+	v1, err := newBulletproofParamsWithDerivation(2, operation.GeneratorDerivationV1)
+	Nil(t, err)
+	seen := map[string]bool{}
+	for _, p := range append(append([]*operation.Point{v1.u}, v1.g...), v1.h...) {
+		True(t, p.PointValidNonIdentity())
+		False(t, seen[p.String()])
+		seen[p.String()] = true
+	}
+	g, err := operation.DeriveGenerator(operation.GeneratorDerivationV1, operation.CStringBPG, 0xD800)
+	Nil(t, err)
+	NotEqual(t, g.ToBytesS(), v1.g[0].ToBytesS())
+
+	var buf bytes.Buffer
+	Nil(t, WriteGeneratorSet(&buf, operation.GeneratorDerivationLegacy, 1))
+	True(t, strings.Contains(buf.String(), fmt.Sprintf("\nu 0 %x\n", AggParam.u.ToBytesS())))
+	True(t, strings.Contains(buf.String(), fmt.Sprintf("\nG 4 %x\n", operation.HBase.ToBytesS())))
+}
+
+func TestMSMultBuilderTable(t *testing.T) {
+	n := 16
+	points := make([]*operation.Point, n)
+	for i := range points {
+		points[i] = operation.RandomPoint()
+	}
+	table := operation.NewMSMTable(points)
+
+	offset := 3
+	tableScalars := []*operation.Scalar{operation.ScZero, operation.ScOne, operation.ScMinusOne}
+	for len(tableScalars) < n-offset {
+		tableScalars = append(tableScalars, operation.RandomScalar())
+	}
+	dynScalars := []*operation.Scalar{operation.RandomScalar(), operation.ScMinusOne}
+	dynPoints := []*operation.Point{operation.RandomPoint(), operation.RandomPoint()}
+
+	expected := new(operation.Point).MultiScalarMult(append(append([]*operation.Scalar{}, tableScalars...), dynScalars...), append(append([]*operation.Point{}, points[offset:]...), dynPoints...))
+	b := NewMSMultBuilder(true)
+	Error(t, b.AppendTable(tableScalars, table, offset+1))
+	NoError(t, b.AppendTable(tableScalars, table, offset))
+	b.Append(dynScalars, dynPoints)
+	True(t, operation.IsPointEqual(expected, b.Clone().Execute()))
+	merged := NewMSMultBuilder(false)
+	merged.AppendBuilderWithMultiplier(b, operation.ScMinusOne)
+	merged.AppendBuilderWithMultiplier(b, operation.ScOne)
+	True(t, merged.Execute().IsIdentity())
+}
+
+func TestParallelMSMultBuilder(t *testing.T) {
+	n := 300
+	points := make([]*operation.Point, n)
+	scalars := make([]*operation.Scalar, n)
+	for i := range points {
+		points[i] = operation.RandomPoint()
+		scalars[i] = operation.RandomScalar()
+	}
+	table := operation.NewMSMTable(points)
+	terms := []operation.MSMTableTerms{{Table: table, Offset: 10, Scalars: scalars[10:200]}}
+	expected := new(operation.Point).VarTimeMultiScalarMultTables(terms, scalars, points)
+
+	for _, workers := range []int{0, 1, 2, 3, 7, 64} {
+		b := NewMSMultBuilder(true).SetWorkers(workers)
+		b.AppendTable(scalars[10:200], table, 10)
+		b.Append(scalars, points)
+		True(t, operation.IsPointEqual(expected, b.Execute()))
+	}
+	// batch verification gives the same answer with parallel MSMs
+	defer func(w int) { MSMWorkers = w }(MSMWorkers)
+	MSMWorkers = 4
+	var proofs []*AggregatedRangeProof
+	for i := 0; i < 3; i++ {
+		wit := new(AggregatedRangeWitness)
+		wit.Set([]uint64{rand.Uint64(), rand.Uint64()}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+		proof, err := wit.Prove()
+		NoError(t, err)
+		valid, err := proof.VerifyFaster()
+		True(t, valid)
+		NoError(t, err)
+		proofs = append(proofs, proof)
+	}
+	valid, err, _ := VerifyBatch(proofs)
+	True(t, valid)
+	NoError(t, err)
+}
+
+func TestBatchInverse(t *testing.T) {
+	scalars := []*operation.Scalar{operation.RandomScalar(), operation.ScOne, operation.RandomScalar(), operation.ScMinusOne}
+	inverses, err := batchInverse(scalars)
+	NoError(t, err)
+	for i := range scalars {
+		True(t, operation.IsScalarEqual(inverses[i], new(operation.Scalar).Invert(scalars[i])))
+	}
+	_, err = batchInverse([]*operation.Scalar{operation.RandomScalar(), operation.NewScalar().FromUint64(0)})
+	Error(t, err)
+}
+
+func TestProofEncodings(t *testing.T) {
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{5, 1 << 40}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+	proof, err := wit.Prove()
+	Nil(t, err)
+
+	jsonBytes, err := json.Marshal(proof)
+	Nil(t, err)
+	fromJSON := new(AggregatedRangeProof)
+	Nil(t, json.Unmarshal(jsonBytes, fromJSON))
+	Equal(t, proof.Bytes(), fromJSON.Bytes())
+	valid, err := fromJSON.Verify()
+	Nil(t, err)
+	True(t, valid)
+
+	text, err := proof.MarshalText()
+	Nil(t, err)
+	fromText := new(AggregatedRangeProof)
+	Nil(t, fromText.UnmarshalText(text))
+	Equal(t, proof.Bytes(), fromText.Bytes())
+	NotNil(t, fromText.UnmarshalText([]byte("zz")))
+
+	bin, err := proof.MarshalBinary()
+	Nil(t, err)
+	fromBinary := new(AggregatedRangeProof)
+	Nil(t, fromBinary.UnmarshalBinary(bin))
+	Equal(t, proof.Bytes(), fromBinary.Bytes())
+	NotNil(t, fromBinary.UnmarshalBinary(nil))
+	// binary and text forms are strict: trailing bytes are rejected
+	NotNil(t, fromBinary.UnmarshalBinary(append(append([]byte{}, bin...), 0)))
+	NotNil(t, fromText.UnmarshalText(append(append([]byte{}, text...), "00"...)))
+	ipBin, err := proof.innerProductProof.MarshalBinary()
+	Nil(t, err)
+	Nil(t, new(InnerProductProof).UnmarshalBinary(ipBin))
+	NotNil(t, new(InnerProductProof).UnmarshalBinary(append(ipBin, 0)))
+
+	ipJSON, err := json.Marshal(proof.innerProductProof)
+	Nil(t, err)
+	ip := new(InnerProductProof)
+	Nil(t, json.Unmarshal(ipJSON, ip))
+	Equal(t, proof.innerProductProof.Bytes(), ip.Bytes())
+	NotNil(t, json.Unmarshal([]byte(`{"l":[],"r":[]}`), ip))
+	NotNil(t, json.Unmarshal([]byte(`{"a":"00"}`), new(AggregatedRangeProof)))
+
+	// scalars and points reject non-canonical encodings
+	var s operation.Scalar
+	NotNil(t, s.UnmarshalText([]byte(strings.Repeat("ff", 32))))
+	var p operation.Point
+	NotNil(t, p.UnmarshalJSON([]byte(`"`+strings.Repeat("ff", 32)+`"`)))
+	sJSON, err := json.Marshal(proof.tHat)
+	Nil(t, err)
+	Nil(t, json.Unmarshal(sJSON, &s))
+	True(t, operation.IsScalarEqual(&s, proof.tHat))
+}
+
+func TestProveWithRand(t *testing.T) {
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{3, 1000}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+
+	proof1, err := wit.ProveWithRand(rand.New(rand.NewSource(42)))
+	Nil(t, err)
+	proof2, err := wit.ProveWithRand(rand.New(rand.NewSource(42)))
+	Nil(t, err)
+	Equal(t, proof1.Bytes(), proof2.Bytes())
+	valid, err := proof1.Verify()
+	Nil(t, err)
+	True(t, valid)
+
+	// a source that runs dry must fail the proof instead of producing weak blinders
+	_, err = wit.ProveWithRand(bytes.NewReader(make([]byte, 100)))
+	NotNil(t, err)
+	_, err = operation.RandomScalarFrom(bytes.NewReader(nil))
+	NotNil(t, err)
+}
+
+func TestProveDeterministic(t *testing.T) {
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{12, 1 << 63}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+
+	proof1, err := wit.ProveDeterministic([]byte("tx-1"), nil)
+	Nil(t, err)
+	proof2, err := wit.ProveDeterministic([]byte("tx-1"), nil)
+	Nil(t, err)
+	Equal(t, proof1.Bytes(), proof2.Bytes())
+	valid, err := proof1.Verify()
+	Nil(t, err)
+	True(t, valid)
+
+	other, err := wit.ProveDeterministic([]byte("tx-2"), nil)
+	Nil(t, err)
+	NotEqual(t, proof1.Bytes(), other.Bytes())
+	other, err = wit.ProveDeterministic([]byte("tx-1"), []byte{1})
+	Nil(t, err)
+	NotEqual(t, proof1.Bytes(), other.Bytes())
+}
+
+func TestBitWidthRangeProof(t *testing.T) {
+	var batch []*AggregatedRangeProof
+	for _, bitWidth := range []int{8, 16, 32, 64} {
+		values := []uint64{0, 1<<uint(bitWidth) - 1, 3}
+		rands := []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar(), operation.RandomScalar()}
+		wit := new(AggregatedRangeWitness)
+		wit.Set(values, rands)
+		Nil(t, wit.SetBitWidth(bitWidth))
+		proof, err := wit.Prove()
+		Nil(t, err)
+		Equal(t, bitWidth, proof.BitWidth())
+		Equal(t, int(EstimateMultiRangeProofSizeWithBitWidth(len(values), bitWidth)), len(proof.Bytes()))
+
+		valid, err := proof.Verify()
+		Nil(t, err)
+		True(t, valid)
+		valid, err = proof.VerifyFaster()
+		Nil(t, err)
+		True(t, valid)
+
+		decoded := new(AggregatedRangeProof)
+		Nil(t, decoded.SetBytes(proof.Bytes()))
+		Equal(t, bitWidth, decoded.BitWidth())
+		valid, err = decoded.VerifyFaster()
+		Nil(t, err)
+		True(t, valid)
+		batch = append(batch, decoded)
+
+		if bitWidth < 64 {
+			tooBig := new(AggregatedRangeWitness)
+			tooBig.Set([]uint64{1 << uint(bitWidth)}, rands[:1])
+			Nil(t, tooBig.SetBitWidth(bitWidth))
+			_, err = tooBig.Prove()
+			NotNil(t, err)
+
+			// relabelling the width breaks either the dimension check or the transcript
+			for _, other := range []int{8, 16, 32, 64} {
+				if other == bitWidth {
+					continue
+				}
+				relabelled := *proof
+				relabelled.bitWidth = other
+				valid, _ = relabelled.VerifyFaster()
+				False(t, valid)
+				valid, _ = relabelled.Verify()
+				False(t, valid)
+			}
+		}
+	}
+	valid, err, _ := VerifyBatch(batch)
+	Nil(t, err)
+	True(t, valid)
+
+	NotNil(t, new(AggregatedRangeWitness).SetBitWidth(24))
+	NotNil(t, new(AggregatedRangeProof).SetBytes([]byte{bitWidthFlag | 64, 1}))
+}
+
+func TestIntervalRangeProof(t *testing.T) {
+	cases := []struct{ value, min, max uint64 }{
+		{150, 100, 200},
+		{100, 100, 100},
+		{70000, 1, 1 << 20},
+		{1 << 63, 0, 1<<64 - 1},
+		{5, 5, 1<<64 - 1},
+	}
+	for _, c := range cases {
+		wit := new(IntervalRangeWitness)
+		Nil(t, wit.Set(c.value, operation.RandomScalar(), c.min, c.max))
+		cm := wit.Commitment()
+		proof, err := wit.Prove()
+		Nil(t, err)
+		valid, err := proof.Verify(cm, c.min, c.max)
+		Nil(t, err)
+		True(t, valid)
+
+		decoded := new(IntervalRangeProof)
+		Nil(t, decoded.SetBytes(proof.Bytes()))
+		valid, err = decoded.Verify(cm, c.min, c.max)
+		Nil(t, err)
+		True(t, valid)
+
+		// the same proof must not verify against other bounds of the same width, or another commitment
+		if c.max < 1<<64-1 {
+			valid, _ = proof.Verify(cm, c.min+1, c.max+1)
+			False(t, valid)
+		}
+		valid, _ = proof.Verify(new(operation.Point).Add(cm, operation.PedCom.G[operation.PedersenValueIndex]), c.min, c.max)
+		False(t, valid)
+		// cm + G with [min+1, max+1] gives the same shifted commitments; only the transcript tells them apart
+		if c.max < 1<<64-1 {
+			valid, _ = proof.Verify(new(operation.Point).Add(cm, operation.PedCom.G[operation.PedersenValueIndex]), c.min+1, c.max+1)
+			False(t, valid)
+		}
+		shifted := *proof.proof
+		shifted.cmsValue = intervalCommitments(cm, c.min, c.max)
+		valid, _ = shifted.VerifyFaster()
+		False(t, valid)
+	}
+
+	wit := new(IntervalRangeWitness)
+	NotNil(t, wit.Set(99, operation.RandomScalar(), 100, 200))
+	NotNil(t, wit.Set(201, operation.RandomScalar(), 100, 200))
+	NotNil(t, wit.Set(150, operation.RandomScalar(), 200, 100))
+}
+
+func TestProveWithContext(t *testing.T) {
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{42, 7, 9}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar(), operation.RandomScalar()})
+	context := []byte("tx hash")
+	proof, err := wit.ProveWithContext(context)
+	Nil(t, err)
+	valid, err := proof.VerifyWithContext(context)
+	Nil(t, err)
+	True(t, valid)
+
+	valid, _ = proof.VerifyWithContext([]byte("another tx"))
+	False(t, valid)
+	valid, _ = proof.VerifyFaster()
+	False(t, valid)
+
+	// v2 binds the commitments, so swapping two of them breaks the proof
+	swapped := *proof
+	swapped.cmsValue = []*operation.Point{proof.cmsValue[1], proof.cmsValue[0], proof.cmsValue[2]}
+	valid, _ = swapped.VerifyWithContext(context)
+	False(t, valid)
+
+	// legacy proofs still verify with the legacy transcript only
+	legacy, err := wit.Prove()
+	Nil(t, err)
+	valid, err = legacy.Verify()
+	Nil(t, err)
+	True(t, valid)
+	valid, _ = legacy.VerifyWithContext(nil)
+	False(t, valid)
+}
+
+// dalekProve mirrors RangeProof::prove_multiple of the dalek crate with a single dealer, so that DalekRangeProof.Verify
+// can be checked without the Rust library
+func dalekProve(gens *DalekGens, label []byte, values []uint64, blindings []*operation.Scalar, n int) (*DalekRangeProof, []*operation.Point) {
+	m := len(values)
+	N := n * m
+	transcript := operation.NewMerlinTranscript(label)
+	transcript.AppendMessage([]byte("dom-sep"), []byte("rangeproof v1"))
+	transcript.AppendUint64([]byte("n"), uint64(n))
+	transcript.AppendUint64([]byte("m"), uint64(m))
+
+	var G, H []*operation.Point
+	for j := 0; j < m; j++ {
+		G = append(G, gens.g[j][:n]...)
+		H = append(H, gens.h[j][:n]...)
+	}
+	commitments := make([]*operation.Point, m)
+	aL := operation.NewScalarVector(N)
+	for j := range values {
+		commitments[j] = gens.Commit(values[j], blindings[j])
+		dalekAppendPoint(transcript, "V", commitments[j])
+		setBits(aL[j*n:(j+1)*n], values[j])
+	}
+	aR := operation.NewScalarVector(N).AddScalar(aL, operation.ScMinusOne)
+	sL := operation.NewScalarVector(N).Random()
+	sR := operation.NewScalarVector(N).Random()
+	alpha, rho := operation.RandomScalar(), operation.RandomScalar()
+
+	proof := new(DalekRangeProof)
+	proof.a = new(operation.Point).Add(new(operation.Point).MultiScalarMult(append(aL.Ptrs(), aR.Ptrs()...), append(append([]*operation.Point{}, G...), H...)), new(operation.Point).ScalarMult(gens.BBlinding, alpha))
+	proof.s = new(operation.Point).Add(new(operation.Point).MultiScalarMult(append(sL.Ptrs(), sR.Ptrs()...), append(append([]*operation.Point{}, G...), H...)), new(operation.Point).ScalarMult(gens.BBlinding, rho))
+	dalekAppendPoint(transcript, "A", proof.a)
+	dalekAppendPoint(transcript, "S", proof.s)
+	y := transcript.ChallengeScalar([]byte("y"))
+	z := transcript.ChallengeScalar([]byte("z"))
+	zSquare := new(operation.Scalar).Mul(z, z)
+
+	// l(X) = aL - z + sL*X, r(X) = y^i * (aR + z + sR*X) + z^2 * z^j * 2^k
+	yPowers := powerVector(y, N)
+	twoPowers := powerVector(new(operation.Scalar).FromUint64(2), n)
+	l0 := operation.NewScalarVector(N).AddScalar(aL, new(operation.Scalar).Sub(operation.ScZero, z))
+	r0 := operation.NewScalarVector(N).AddScalar(aR, z)
+	r0.Hadamard(r0, yPowers)
+	zExp := new(operation.Scalar).Set(zSquare)
+	for j := 0; j < m; j++ {
+		r0[j*n:(j+1)*n].MulScalarAdd(twoPowers, zExp, r0[j*n:(j+1)*n])
+		zExp.Mul(zExp, z)
+	}
+	r1 := operation.NewScalarVector(N).Hadamard(sR, yPowers)
+	t1 := new(operation.Scalar).Add(l0.InnerProduct(r1), sL.InnerProduct(r0))
+	t2 := sL.InnerProduct(r1)
+	tau1, tau2 := operation.RandomScalar(), operation.RandomScalar()
+	proof.t1 = gens.Commit(0, tau1).Add(gens.Commit(0, tau1), new(operation.Point).ScalarMult(gens.B, t1))
+	proof.t2 = gens.Commit(0, tau2).Add(gens.Commit(0, tau2), new(operation.Point).ScalarMult(gens.B, t2))
+	dalekAppendPoint(transcript, "T_1", proof.t1)
+	dalekAppendPoint(transcript, "T_2", proof.t2)
+	x := transcript.ChallengeScalar([]byte("x"))
+
+	l := l0.MulScalarAdd(sL, x, l0)
+	r := r0.MulScalarAdd(r1, x, r0)
+	proof.tX = l.InnerProduct(r)
+	proof.tXBlinding = new(operation.Scalar).Mul(tau2, new(operation.Scalar).Mul(x, x))
+	proof.tXBlinding.Add(proof.tXBlinding, new(operation.Scalar).Mul(tau1, x))
+	zExp.Set(zSquare)
+	for j := 0; j < m; j++ {
+		proof.tXBlinding.Add(proof.tXBlinding, new(operation.Scalar).Mul(zExp, blindings[j]))
+		zExp.Mul(zExp, z)
+	}
+	proof.eBlinding = new(operation.Scalar).Add(alpha, new(operation.Scalar).Mul(rho, x))
+	transcript.AppendMessage([]byte("t_x"), proof.tX.ToBytesS())
+	transcript.AppendMessage([]byte("t_x_blinding"), proof.tXBlinding.ToBytesS())
+	transcript.AppendMessage([]byte("e_blinding"), proof.eBlinding.ToBytesS())
+	w := transcript.ChallengeScalar([]byte("w"))
+	Q := new(operation.Point).ScalarMult(gens.B, w)
+
+	// inner product argument over G and H' = H * y^-i
+	transcript.AppendMessage([]byte("dom-sep"), []byte("ipp v1"))
+	transcript.AppendUint64([]byte("n"), uint64(N))
+	Hp := operation.NewPointVector(N).ScalarMult(operation.PointVectorFromSlice(H), powerVector(new(operation.Scalar).Invert(y), N))
+	Gp := operation.PointVectorFromSlice(G)
+	a, b := l, r
+	for len(a) > 1 {
+		half := len(a) / 2
+		cL := a[:half].InnerProduct(b[half:])
+		cR := a[half:].InnerProduct(b[:half])
+		L := new(operation.Point).MultiScalarMult(append(append(a[:half].Clone().Ptrs(), b[half:].Clone().Ptrs()...), cL), append(append(Gp[half:].Clone().Ptrs(), Hp[:half].Clone().Ptrs()...), Q))
+		R := new(operation.Point).MultiScalarMult(append(append(a[half:].Clone().Ptrs(), b[:half].Clone().Ptrs()...), cR), append(append(Gp[:half].Clone().Ptrs(), Hp[half:].Clone().Ptrs()...), Q))
+		proof.l = append(proof.l, L)
+		proof.r = append(proof.r, R)
+		dalekAppendPoint(transcript, "L", L)
+		dalekAppendPoint(transcript, "R", R)
+		u := transcript.ChallengeScalar([]byte("u"))
+		uInv := new(operation.Scalar).Invert(u)
+		a = a[:half].Fold(a[:half], a[half:], u, uInv)
+		b = b[:half].Fold(b[:half], b[half:], uInv, u)
+		Gp = Gp[:half].Fold(Gp[:half], Gp[half:], uInv, u)
+		Hp = Hp[:half].Fold(Hp[:half], Hp[half:], u, uInv)
+	}
+	proof.ipA, proof.ipB = &a[0], &b[0]
+	return proof, commitments
+}
+
+func TestDalekRangeProof(t *testing.T) {
+	gens := DalekParam()
+	for _, m := range []int{1, 2, 4} {
+		for _, n := range []int{8, 64} {
+			values := make([]uint64, m)
+			blindings := make([]*operation.Scalar, m)
+			for i := range values {
+				values[i] = rand.Uint64() >> uint(64-n)
+				blindings[i] = operation.RandomScalar()
+			}
+			proof, commitments := dalekProve(gens, DalekTranscriptLabel, values, blindings, n)
+			decoded := new(DalekRangeProof)
+			Nil(t, decoded.SetBytes(proof.Bytes()))
+			Equal(t, proof.Bytes(), decoded.Bytes())
+			valid, err := decoded.Verify(gens, DalekTranscriptLabel, commitments, n)
+			Nil(t, err)
+			True(t, valid)
+
+			valid, _ = decoded.Verify(gens, []byte("other label"), commitments, n)
+			False(t, valid)
+			tampered := append([]*operation.Point{}, commitments...)
+			tampered[0] = new(operation.Point).Add(tampered[0], gens.B)
+			valid, _ = decoded.Verify(gens, DalekTranscriptLabel, tampered, n)
+			False(t, valid)
+		}
+	}
+	NotNil(t, new(DalekRangeProof).SetBytes(make([]byte, 8*32)))
+}
+
+// TestDalekCgoProof cross-checks proofs from the Rust library; it is skipped when libnewbp is a stub
+func TestDalekCgoProof(t *testing.T) {
+	values := []uint64{1, 1 << 40}
+	blindings := []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()}
+	raw := make([][32]byte, len(blindings))
+	for i := range blindings {
+		copy(raw[i][:], blindings[i].ToBytesS())
+	}
+	proofBytes := ExtProve(values, raw)
+	if len(proofBytes) == 0 {
+		t.Skip("libnewbp returned no proof")
+	}
+	proof := new(DalekRangeProof)
+	Nil(t, proof.SetBytes(proofBytes))
+	gens := DalekParam()
+	valid, err := proof.Verify(gens, DalekTranscriptLabel, []*operation.Point{gens.Commit(values[0], blindings[0]), gens.Commit(values[1], blindings[1])}, 64)
+	Nil(t, err)
+	True(t, valid)
+}
+
+func TestBulletproofsPlus(t *testing.T) {
+	for _, numOutputs := range []int{1, 2, 3, 8} {
+		for _, bitWidth := range []int{8, 64} {
+			values := make([]uint64, numOutputs)
+			rands := make([]*operation.Scalar, numOutputs)
+			for i := range values {
+				values[i] = rand.Uint64() >> uint(64-bitWidth)
+				rands[i] = operation.RandomScalar()
+			}
+			wit := new(AggregatedRangeWitness)
+			wit.Set(values, rands)
+			Nil(t, wit.SetBitWidth(bitWidth))
+			proof, err := wit.ProvePlus()
+			Nil(t, err)
+			True(t, proof.ValidateSanity())
+			valid, err := proof.Verify()
+			Nil(t, err)
+			True(t, valid)
+
+			// the proof is smaller than a Bulletproof for the same statement
+			b := proof.Bytes()
+			Equal(t, EstimateMultiRangeProofPlusSize(numOutputs, bitWidth), uint64(len(b)))
+			Less(t, len(b), int(EstimateMultiRangeProofSizeWithBitWidth(numOutputs, bitWidth)))
+			decoded := new(AggregatedRangeProofPlus)
+			Nil(t, decoded.SetBytes(b))
+			Equal(t, b, decoded.Bytes())
+			valid, err = decoded.Verify()
+			Nil(t, err)
+			True(t, valid)
+			NotNil(t, decoded.SetBytes(append(b, 0)))
+			NotNil(t, decoded.SetBytes(b[:len(b)-1]))
+
+			// a different commitment or response fails
+			tampered := *proof
+			tampered.cmsValue = append([]*operation.Point{}, proof.cmsValue...)
+			tampered.cmsValue[0] = new(operation.Point).Add(proof.cmsValue[0], operation.PedCom.G[operation.PedersenValueIndex])
+			valid, err = tampered.Verify()
+			NotNil(t, err)
+			False(t, valid)
+			tampered = *proof
+			tampered.d1 = new(operation.Scalar).Add(proof.d1, operation.ScOne)
+			valid, _ = tampered.Verify()
+			False(t, valid)
+		}
+	}
+
+	// values out of range cannot be proven
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{256}, []*operation.Scalar{operation.RandomScalar()})
+	Nil(t, wit.SetBitWidth(8))
+	_, err := wit.ProvePlus()
+	NotNil(t, err)
+}
+
+func TestBulletproofsPlusUsingBase(t *testing.T) {
+	assetTag := operation.RandomPoint()
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{5, 1 << 50}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+	proof, err := wit.ProvePlusUsingBase(assetTag)
+	Nil(t, err)
+	True(t, operation.IsPointEqual(proof.cmsValue[0], new(operation.Point).AddPedersen(new(operation.Scalar).FromUint64(5), assetTag, wit.rands[0], operation.HBase)))
+	valid, err := proof.VerifyUsingBase(assetTag)
+	Nil(t, err)
+	True(t, valid)
+
+	valid, _ = proof.VerifyUsingBase(operation.RandomPoint())
+	False(t, valid)
+	valid, _ = proof.Verify()
+	False(t, valid)
+}
+
+func TestVerifyBatchPlus(t *testing.T) {
+	var proofs []*AggregatedRangeProofPlus
+	for _, numOutputs := range []int{1, 2, 5, 16} {
+		values := make([]uint64, numOutputs)
+		rands := make([]*operation.Scalar, numOutputs)
+		for i := range values {
+			values[i] = rand.Uint64()
+			rands[i] = operation.RandomScalar()
+		}
+		wit := new(AggregatedRangeWitness)
+		wit.Set(values, rands)
+		proof, err := wit.ProvePlus()
+		Nil(t, err)
+		proofs = append(proofs, proof)
+	}
+	valid, err, _ := VerifyBatchPlus(proofs)
+	Nil(t, err)
+	True(t, valid)
+
+	bad := *proofs[2]
+	bad.r1 = new(operation.Scalar).Add(bad.r1, operation.ScOne)
+	valid, err, _ = VerifyBatchPlus([]*AggregatedRangeProofPlus{proofs[0], proofs[1], &bad, proofs[3]})
+	NotNil(t, err)
+	False(t, valid)
+
+	malformed := *proofs[1]
+	malformed.l = malformed.l[1:]
+	valid, _, index := VerifyBatchPlus([]*AggregatedRangeProofPlus{proofs[0], &malformed})
+	False(t, valid)
+	Equal(t, 1, index)
+}
+
+// runMPC runs the dealer/party protocol, passing every message through its byte encoding.
+// corrupt, if not nil, may alter the shares before the dealer sees them.
+func runMPC(t *testing.T, values []uint64, rands []*operation.Scalar, bitWidth int, corrupt func([]*ProofShare)) (*AggregatedRangeProof, error) {
+	dealer, err := NewDealer(bitWidth, len(values))
+	Nil(t, err)
+	parties := make([]*Party, len(values))
+	bitCommitments := make([]*BitCommitment, len(values))
+	for j := range values {
+		parties[j], err = NewParty(values[j], rands[j], bitWidth)
+		Nil(t, err)
+		bc, err := parties[j].AssignPosition(j)
+		Nil(t, err)
+		bitCommitments[j] = new(BitCommitment)
+		Nil(t, bitCommitments[j].SetBytes(bc.Bytes()))
+	}
+	bitChallenge, err := dealer.ReceiveBitCommitments(bitCommitments)
+	Nil(t, err)
+	received := new(BitChallenge)
+	Nil(t, received.SetBytes(bitChallenge.Bytes()))
+
+	polyCommitments := make([]*PolyCommitment, len(values))
+	for j, party := range parties {
+		pc, err := party.ApplyBitChallenge(received)
+		Nil(t, err)
+		polyCommitments[j] = new(PolyCommitment)
+		Nil(t, polyCommitments[j].SetBytes(pc.Bytes()))
+	}
+	polyChallenge, err := dealer.ReceivePolyCommitments(polyCommitments)
+	Nil(t, err)
+	receivedPoly := new(PolyChallenge)
+	Nil(t, receivedPoly.SetBytes(polyChallenge.Bytes()))
+
+	shares := make([]*ProofShare, len(values))
+	for j, party := range parties {
+		share, err := party.ApplyPolyChallenge(receivedPoly)
+		Nil(t, err)
+		shares[j] = new(ProofShare)
+		Nil(t, shares[j].SetBytes(share.Bytes()))
+	}
+	if corrupt != nil {
+		corrupt(shares)
+	}
+	return dealer.ReceiveShares(shares)
+}
+
+func TestMPCRangeProof(t *testing.T) {
+	for _, numParties := range []int{1, 3, 4} {
+		for _, bitWidth := range []int{16, 64} {
+			values := make([]uint64, numParties)
+			rands := make([]*operation.Scalar, numParties)
+			for i := range values {
+				values[i] = rand.Uint64() >> uint(64-bitWidth)
+				rands[i] = operation.RandomScalar()
+			}
+			proof, err := runMPC(t, values, rands, bitWidth, nil)
+			Nil(t, err)
+			Equal(t, bitWidth, proof.BitWidth())
+			for j := range values {
+				True(t, operation.IsPointEqual(proof.cmsValue[j], operation.PedCom.CommitAtIndex(new(operation.Scalar).FromUint64(values[j]), rands[j], operation.PedersenValueIndex)))
+			}
+			valid, err := proof.Verify()
+			Nil(t, err)
+			True(t, valid)
+			valid, err = proof.VerifyFaster()
+			Nil(t, err)
+			True(t, valid)
+		}
+	}
+}
+
+func TestMPCMisbehavingParty(t *testing.T) {
+	values := []uint64{1, 2, 3}
+	rands := []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar(), operation.RandomScalar()}
+	_, err := runMPC(t, values, rands, 64, func(shares []*ProofShare) {
+		shares[1].tauX = new(operation.Scalar).Add(shares[1].tauX, operation.ScOne)
+		shares[2].l[0].Add(&shares[2].l[0], operation.ScOne)
+		shares[2].tX = shares[2].l.InnerProduct(shares[2].r)
+	})
+	shareErr, ok := err.(*ProofShareError)
+	True(t, ok)
+	Equal(t, []int{1, 2}, shareErr.BadShares)
+
+	// out-of-order rounds and malformed messages are rejected
+	party, err := NewParty(1, operation.RandomScalar(), 8)
+	Nil(t, err)
+	_, err = party.ApplyPolyChallenge(&PolyChallenge{x: operation.RandomScalar()})
+	NotNil(t, err)
+	_, err = NewParty(256, operation.RandomScalar(), 8)
+	NotNil(t, err)
+	dealer, err := NewDealer(64, 2)
+	Nil(t, err)
+	_, err = dealer.ReceiveShares(nil)
+	NotNil(t, err)
+	_, err = dealer.ReceiveBitCommitments([]*BitCommitment{})
+	NotNil(t, err)
+	NotNil(t, new(ProofShare).SetBytes(make([]byte, 10*operation.Ed25519KeySize)))
+	// l and r must have the same length
+	Nil(t, new(ProofShare).SetBytes(make([]byte, (3+2*8)*operation.Ed25519KeySize)))
+	NotNil(t, new(ProofShare).SetBytes(make([]byte, (3+2*8+1)*operation.Ed25519KeySize)))
+}
+
+func TestVerifyBatchIdentify(t *testing.T) {
+	proofs := make([]*AggregatedRangeProof, 11)
+	for i := range proofs {
+		wit := new(AggregatedRangeWitness)
+		wit.Set([]uint64{rand.Uint64()}, []*operation.Scalar{operation.RandomScalar()})
+		proof, err := wit.Prove()
+		Nil(t, err)
+		proofs[i] = proof
+	}
+	Nil(t, VerifyBatchIdentify(proofs))
+
+	bad := append([]*AggregatedRangeProof{}, proofs...)
+	for _, i := range []int{2, 7} {
+		tampered := *proofs[i]
+		tampered.tHat = new(operation.Scalar).Add(tampered.tHat, operation.ScOne)
+		bad[i] = &tampered
+	}
+	malformed := *proofs[9]
+	malformed.bitWidth = 12
+	bad[9] = &malformed
+	bad[10] = nil
+	err := VerifyBatchIdentify(bad)
+	invalid, ok := err.(*InvalidProofsError)
+	True(t, ok)
+	Equal(t, []int{2, 7, 9, 10}, invalid.Indices)
+}
+
+func TestBatchVerifier(t *testing.T) {
+	bv := NewBatchVerifier()
+	valid, err := bv.Verify()
+	Nil(t, err)
+	True(t, valid)
+
+	var proofs []*AggregatedRangeProof
+	for _, numOutputs := range []int{1, 3, 8} {
+		values := make([]uint64, numOutputs)
+		rands := make([]*operation.Scalar, numOutputs)
+		bitWidth := 64
+		if numOutputs == 3 {
+			bitWidth = 32
+		}
+		for i := range values {
+			values[i] = rand.Uint64() >> uint(64-bitWidth)
+			rands[i] = operation.RandomScalar()
+		}
+		wit := new(AggregatedRangeWitness)
+		wit.Set(values, rands)
+		Nil(t, wit.SetBitWidth(bitWidth))
+		proof, err := wit.Prove()
+		Nil(t, err)
+		proofs = append(proofs, proof)
+	}
+	assetTag := operation.RandomPoint()
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{7, 9}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+	caProof, err := wit.ProveUsingBase(assetTag)
+	Nil(t, err)
+	// CA proofs carry the coins' commitments, which the caller sets
+	caProof.SetCommitments([]*operation.Point{
+		new(operation.Point).AddPedersen(new(operation.Scalar).FromUint64(7), assetTag, wit.rands[0], operation.HBase),
+		new(operation.Point).AddPedersen(new(operation.Scalar).FromUint64(9), assetTag, wit.rands[1], operation.HBase),
+	})
+	valid, err = caProof.VerifyFasterUsingBase(assetTag)
+	Nil(t, err)
+	True(t, valid)
+
+	// enough proofs to fold the pending terms several times
+	for i := 0; i < 40; i++ {
+		Nil(t, bv.Add(proofs[i%len(proofs)]))
+		Nil(t, bv.AddUsingBase(caProof, assetTag))
+		LessOrEqual(t, len(bv.pending.scalars), batchVerifierFlushTerms)
+	}
+	Equal(t, 80, bv.Len())
+	valid, err = bv.Verify()
+	Nil(t, err)
+	True(t, valid)
+
+	// a malformed proof is rejected without touching the batch
+	malformed := *proofs[0]
+	malformed.bitWidth = 12
+	NotNil(t, bv.Add(&malformed))
+	NotNil(t, bv.AddUsingBase(caProof, nil))
+	Equal(t, 80, bv.Len())
+
+	// one bad proof or a wrong asset tag fails the whole batch
+	tampered := *proofs[1]
+	tampered.mu = new(operation.Scalar).Add(tampered.mu, operation.ScOne)
+	Nil(t, bv.Add(&tampered))
+	valid, err = bv.Verify()
+	NotNil(t, err)
+	False(t, valid)
+	bv = NewBatchVerifier()
+	Nil(t, bv.AddUsingBase(caProof, operation.RandomPoint()))
+	valid, _ = bv.Verify()
+	False(t, valid)
+
+	// proofs over custom parameters are batched with those parameters only
+	params, err := NewRangeProofParamsWithDerivation(operation.GeneratorDerivationV1, 4)
+	Nil(t, err)
+	wit = new(AggregatedRangeWitness)
+	wit.Set([]uint64{3, 5}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+	custom, err := params.Prove(wit)
+	Nil(t, err)
+	customCA, err := params.WithValueBase(assetTag).Prove(wit)
+	Nil(t, err)
+	bv = NewBatchVerifierWithParams(params)
+	Nil(t, bv.Add(custom))
+	Nil(t, bv.AddUsingBase(customCA, assetTag))
+	valid, err = bv.Verify()
+	Nil(t, err)
+	True(t, valid)
+	NotNil(t, bv.Add(proofs[2]))
+	bv = NewBatchVerifier()
+	Nil(t, bv.Add(custom))
+	valid, _ = bv.Verify()
+	False(t, valid)
+}
+
+func TestVerifyBatchUsingBase(t *testing.T) {
+	// CA proofs over two asset tags, mixed with PRV proofs
+	tags := []*operation.Point{operation.RandomPoint(), operation.RandomPoint()}
+	var proofs []*AggregatedRangeProof
+	var assetTags []*operation.Point
+	for i := 0; i < 6; i++ {
+		values := []uint64{rand.Uint64(), rand.Uint64()}
+		rands := []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()}
+		wit := new(AggregatedRangeWitness)
+		wit.Set(values, rands)
+		if i%3 == 2 {
+			proof, err := wit.Prove()
+			Nil(t, err)
+			proofs = append(proofs, proof)
+			assetTags = append(assetTags, nil)
+			continue
+		}
+		tag := tags[i%2]
+		proof, err := wit.ProveUsingBase(tag)
+		Nil(t, err)
+		proof.SetCommitments([]*operation.Point{
+			new(operation.Point).AddPedersen(new(operation.Scalar).FromUint64(values[0]), tag, rands[0], operation.HBase),
+			new(operation.Point).AddPedersen(new(operation.Scalar).FromUint64(values[1]), tag, rands[1], operation.HBase),
+		})
+		proofs = append(proofs, proof)
+		assetTags = append(assetTags, tag)
+	}
+	valid, err, _ := VerifyBatchUsingBase(proofs, assetTags)
+	Nil(t, err)
+	True(t, valid)
+
+	// swapping the tags of two CA proofs fails the batch
+	swapped := append([]*operation.Point{}, assetTags...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	valid, err, _ = VerifyBatchUsingBase(proofs, swapped)
+	NotNil(t, err)
+	False(t, valid)
+	// so does verifying a CA proof as a PRV proof
+	swapped = append([]*operation.Point{}, assetTags...)
+	swapped[0] = nil
+	valid, _, _ = VerifyBatchUsingBase(proofs, swapped)
+	False(t, valid)
+
+	_, err, _ = VerifyBatchUsingBase(proofs, assetTags[1:])
+	NotNil(t, err)
+	malformed := *proofs[4]
+	malformed.innerProductProof = nil
+	valid, _, index := VerifyBatchUsingBase(append(proofs[:4:4], &malformed), assetTags[:5])
+	False(t, valid)
+	Equal(t, 4, index)
+}
+
+func TestRangeProofParams(t *testing.T) {
+	values := []uint64{rand.Uint64(), rand.Uint64(), rand.Uint64()}
+	rands := []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar(), operation.RandomScalar()}
+	wit := new(AggregatedRangeWitness)
+	wit.Set(values, rands)
+
+	// a custom parameter set, as a network using the v1 generators would have
+	params, err := NewRangeProofParamsWithDerivation(operation.GeneratorDerivationV1, 4)
+	Nil(t, err)
+	Equal(t, 4*MaxExp, params.Capacity())
+	proof, err := params.Prove(wit)
+	Nil(t, err)
+	True(t, operation.IsPointEqual(params.Commit(new(operation.Scalar).FromUint64(values[1]), rands[1]), proof.GetCommitments()[1]))
+	valid, err := params.Verify(proof)
+	Nil(t, err)
+	True(t, valid)
+	valid, err = params.VerifyFaster(proof)
+	Nil(t, err)
+	True(t, valid)
+	// the same proof is not valid over the default parameters
+	valid, _ = proof.Verify()
+	False(t, valid)
+	valid, _ = proof.VerifyFaster()
+	False(t, valid)
+	// nor does it fit a parameter set that is too small for it
+	small, err := NewRangeProofParamsWithDerivation(operation.GeneratorDerivationV1, 2)
+	Nil(t, err)
+	_, err = small.Verify(proof)
+	NotNil(t, err)
+	_, err = small.Prove(wit)
+	NotNil(t, err)
+
+	// the default parameters agree with the package-level API
+	proof, err = DefaultRangeProofParams().Prove(wit)
+	Nil(t, err)
+	valid, err = proof.VerifyFaster()
+	Nil(t, err)
+	True(t, valid)
+
+	// WithValueBase gives the CA parameters, and the CA wrappers now check every statement
+	tag := operation.RandomPoint()
+	caParams := DefaultRangeProofParams().WithValueBase(tag)
+	True(t, operation.IsPointEqual(tag, caParams.ValueBase()))
+	proof, err = caParams.Prove(wit)
+	Nil(t, err)
+	for _, verify := range []func(*operation.Point) (bool, error){proof.VerifyUsingBase, proof.VerifyFasterUsingBase} {
+		valid, err = verify(tag)
+		Nil(t, err)
+		True(t, valid)
+		valid, _ = verify(operation.RandomPoint())
+		False(t, valid)
+	}
+	tampered := *proof
+	tampered.mu = operation.RandomScalar()
+	valid, _ = tampered.VerifyUsingBase(tag)
+	False(t, valid)
+
+	_, err = NewRangeProofParams(tag, operation.HBase, AggParam.g[:4], AggParam.h[:2], AggParam.u, AggParam.cs)
+	NotNil(t, err)
+}
+
+// countdownContext is cancelled from the n+1-th call of Err on
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.n <= 0 {
+		return context.Canceled
+	}
+	ctx.n--
+	return nil
+}
+
+func TestContextCancellation(t *testing.T) {
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{rand.Uint64(), rand.Uint64()}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+	proof, err := wit.ProveContext(context.Background())
+	Nil(t, err)
+	valid, err := proof.VerifyContext(context.Background())
+	Nil(t, err)
+	True(t, valid)
+	valid, err, _ = VerifyBatchContext(context.Background(), []*AggregatedRangeProof{proof, proof})
+	Nil(t, err)
+	True(t, valid)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = wit.ProveContext(cancelled)
+	Equal(t, context.Canceled, err)
+	valid, err = proof.VerifyContext(cancelled)
+	Equal(t, context.Canceled, err)
+	False(t, valid)
+	valid, err, index := VerifyBatchContext(cancelled, []*AggregatedRangeProof{proof, proof})
+	Equal(t, context.Canceled, err)
+	False(t, valid)
+	Equal(t, -1, index)
+
+	// cancellation is also noticed between the folding rounds of the inner product argument,
+	// after the checks between protocol rounds: two when proving, one when verifying
+	logN := len(proof.innerProductProof.l)
+	for n := 0; n < 2+logN; n++ {
+		_, err = wit.ProveContext(&countdownContext{context.Background(), n})
+		Equal(t, context.Canceled, err)
+	}
+	for n := 0; n < 1+logN; n++ {
+		_, err = proof.VerifyContext(&countdownContext{context.Background(), n})
+		Equal(t, context.Canceled, err)
+	}
+	_, err = wit.ProveContext(&countdownContext{context.Background(), 2 + logN})
+	Nil(t, err)
+	valid, err = proof.VerifyContext(&countdownContext{context.Background(), 1 + logN})
+	Nil(t, err)
+	True(t, valid)
+}
+
+func proverPoolWitnesses(n, numOutputs int) []*AggregatedRangeWitness {
+	wits := make([]*AggregatedRangeWitness, n)
+	for i := range wits {
+		values := make([]uint64, numOutputs)
+		rands := make([]*operation.Scalar, numOutputs)
+		for j := range values {
+			values[j] = rand.Uint64()
+			rands[j] = operation.RandomScalar()
+		}
+		wits[i] = new(AggregatedRangeWitness)
+		wits[i].Set(values, rands)
+	}
+	return wits
+}
+
+func TestProverPool(t *testing.T) {
+	pool := NewProverPool(3)
+	// witnesses of different sizes make the workers resize their scratch vectors
+	wits := append(proverPoolWitnesses(4, 2), proverPoolWitnesses(3, 5)...)
+	wits = append(wits, proverPoolWitnesses(3, 1)...)
+	for j := range wits[4].values {
+		wits[4].values[j] >>= 32
+	}
+	Nil(t, wits[4].SetBitWidth(32))
+	// a failing job only fails its own result
+	Nil(t, wits[1].SetBitWidth(8))
+	results := pool.ProveAll(context.Background(), wits)
+	Equal(t, len(wits), len(results))
+	for i, result := range results {
+		if i == 1 {
+			NotNil(t, result.Err)
+			continue
+		}
+		Nil(t, result.Err)
+		Equal(t, len(wits[i].values), len(result.Proof.GetCommitments()))
+		valid, err := result.Proof.Verify()
+		Nil(t, err)
+		True(t, valid)
+	}
+
+	// many goroutines may submit at once
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(wit *AggregatedRangeWitness) {
+			defer wg.Done()
+			result := <-pool.Submit(context.Background(), wit)
+			Nil(t, result.Err)
+			valid, _ := result.Proof.VerifyFaster()
+			True(t, valid)
+		}(wits[2+i%3])
+	}
+	wg.Wait()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	Equal(t, context.Canceled, (<-pool.Submit(cancelled, wits[0])).Err)
+
+	pool.Close()
+	pool.Close()
+	Equal(t, ErrProverPoolClosed, (<-pool.Submit(context.Background(), wits[0])).Err)
+}
+
+func BenchmarkProverPool(b *testing.B) {
+	wits := proverPoolWitnesses(32, 2)
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, wit := range wits {
+				if _, err := wit.Prove(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	pool := NewProverPool(0)
+	defer pool.Close()
+	b.Run("pool", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, result := range pool.ProveAll(context.Background(), wits) {
+				if result.Err != nil {
+					b.Fatal(result.Err)
+				}
+			}
+		}
+	})
+}
+
+func TestProofWireFormatV2(t *testing.T) {
+	wit := new(AggregatedRangeWitness)
+	wit.Set([]uint64{rand.Uint64() >> 48, rand.Uint64() >> 48}, []*operation.Scalar{operation.RandomScalar(), operation.RandomScalar()})
+	Nil(t, wit.SetBitWidth(16))
+	proof, err := wit.Prove()
+	Nil(t, err)
+
+	v2 := proof.BytesV2()
+	Equal(t, []byte{ProofVersion2, 16, 2}, v2[:3])
+	Equal(t, len(proof.Bytes())+1, len(v2))
+	// v1 decoders reject v2 proofs
+	NotNil(t, new(AggregatedRangeProof).SetBytes(v2))
+
+	// SetBytes is as strict as SetBytesV2
+	NotNil(t, new(AggregatedRangeProof).SetBytes(nil))
+	NotNil(t, new(AggregatedRangeProof).SetBytes(append(proof.Bytes(), 0)))
+
+	for _, b := range [][]byte{v2, proof.Bytes()} {
+		parsed, err := ParseAggregatedRangeProof(b)
+		Nil(t, err)
+		Equal(t, 16, parsed.BitWidth())
+		Equal(t, proof.Bytes(), parsed.Bytes())
+		valid, err := parsed.Verify()
+		Nil(t, err)
+		True(t, valid)
+
+		_, err = ParseAggregatedRangeProof(append(append([]byte{}, b...), 0))
+		NotNil(t, err)
+		_, err = ParseAggregatedRangeProof(b[:len(b)-1])
+		NotNil(t, err)
+	}
+	_, err = ParseAggregatedRangeProof(nil)
+	NotNil(t, err)
+	NotNil(t, new(AggregatedRangeProof).SetBytesV2(nil))
+
+	// the width is always written, also for 64-bit proofs
+	wit.SetBitWidth(64)
+	proof, err = wit.Prove()
+	Nil(t, err)
+	parsed := new(AggregatedRangeProof)
+	Nil(t, parsed.SetBytesV2(proof.BytesV2()))
+	Equal(t, 64, parsed.BitWidth())
+	Equal(t, proof.Bytes(), parsed.Bytes())
+	invalid := proof.BytesV2()
+	invalid[1] = 12
+	NotNil(t, parsed.SetBytesV2(invalid))
+
+	// counts are varints, which must be minimal
+	nonMinimal := append([]byte{ProofVersion2, 64, 0x82, 0x00}, proof.BytesV2()[3:]...)
+	NotNil(t, parsed.SetBytesV2(nonMinimal))
+	large := *proof
+	for len(large.cmsValue) < 300 {
+		large.cmsValue = append(large.cmsValue, proof.cmsValue...)
+	}
+	b := large.BytesV2()
+	Equal(t, []byte{0xac, 0x02}, b[2:4])
+	Nil(t, parsed.SetBytesV2(b))
+	Equal(t, 300, len(parsed.GetCommitments()))
+	Equal(t, b, parsed.BytesV2())
+	// such a proof has no v1 encoding; MarshalBinary falls back to v2
+	Empty(t, large.Bytes())
+	bin, err := large.MarshalBinary()
+	Nil(t, err)
+	Equal(t, b, bin)
+	Nil(t, parsed.UnmarshalBinary(bin))
+	Equal(t, 300, len(parsed.GetCommitments()))
+	// a count larger than the input is rejected before anything is allocated for it
+	NotNil(t, parsed.SetBytesV2([]byte{ProofVersion2, 64, 0xff, 0xff, 0xff, 0xff, 0x0f}))
+}
+
+// dalekGoldenProof is written by golden_prove_multiple in src/lib.rs: `cargo test golden_prove_multiple`
+const dalekGoldenProof = "testdata/dalek_prove_multiple.hex"
+
+// TestDalekGoldenProof checks a proof of RangeProof::prove_multiple from the dalek crate without cgo.
+// The values and blindings must match golden_prove_multiple.
+func TestDalekGoldenProof(t *testing.T) {
+	encoded, err := ioutil.ReadFile(dalekGoldenProof)
+	if os.IsNotExist(err) {
+		t.Skipf("%s is missing, generate it with `cargo test golden_prove_multiple`", dalekGoldenProof)
+	}
+	Nil(t, err)
+	raw, err := hex.DecodeString(strings.TrimSpace(string(encoded)))
+	Nil(t, err)
+	proof := new(DalekRangeProof)
+	Nil(t, proof.SetBytes(raw))
+
+	values := []uint64{1, 1 << 40, 0, 1<<64 - 1}
+	gens := DalekParam()
+	commitments := make([]*operation.Point, len(values))
+	for i := range values {
+		commitments[i] = gens.Commit(values[i], new(operation.Scalar).FromUint64(uint64(1001+i)))
+	}
+	valid, err := proof.Verify(gens, DalekTranscriptLabel, commitments, DefaultBitWidth)
+	Nil(t, err)
+	True(t, valid)
+
+	commitments[3] = gens.Commit(values[3]-1, new(operation.Scalar).FromUint64(1004))
+	valid, _ = proof.Verify(gens, DalekTranscriptLabel, commitments, DefaultBitWidth)
+	False(t, valid)
+}
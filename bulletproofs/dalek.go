@@ -0,0 +1,338 @@
+package bulletproofs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"sync"
+
+	"github.com/dat-incognito-org/newbp/operation"
+	"github.com/ebfe/keccak"
+	"github.com/pkg/errors"
+)
+
+// Verification of range proofs made by the dalek bulletproofs crate (RangeProof::prove_multiple), as returned by
+// ExtProve. Those proofs live on ristretto255, use a Merlin transcript and dalek's own generators, so they share
+// nothing with AggregatedRangeProof beyond the underlying curve.
+
+// DalekTranscriptLabel is the Merlin transcript label ExtProve creates its proofs with
+var DalekTranscriptLabel = []byte("")
+
+// DalekGens holds dalek's PedersenGens and BulletproofGens
+type DalekGens struct {
+	B         *operation.Point
+	BBlinding *operation.Point
+	// g[j], h[j] are the generators of party j
+	g [][]*operation.Point
+	h [][]*operation.Point
+}
+
+// dalekGeneratorsChain returns the first n points of dalek's GeneratorsChain for label:
+// SHAKE256("GeneratorsChain" || label), read 64 bytes at a time through RistrettoPoint::from_uniform_bytes
+func dalekGeneratorsChain(label []byte, n int) []*operation.Point {
+	shake := keccak.NewSHAKE256(64 * n)
+	shake.Write([]byte("GeneratorsChain"))
+	shake.Write(label)
+	stream := shake.Sum(nil)
+	result := make([]*operation.Point, n)
+	for i := range result {
+		result[i], _ = new(operation.Point).SetRistrettoUniformBytes(stream[64*i : 64*(i+1)])
+	}
+	return result
+}
+
+// NewDalekGens derives the generators of BulletproofGens::new(gensCapacity, partyCapacity) and PedersenGens::default()
+func NewDalekGens(gensCapacity, partyCapacity int) *DalekGens {
+	gens := &DalekGens{
+		B: operation.NewGeneratorPoint(),
+		g: make([][]*operation.Point, partyCapacity),
+		h: make([][]*operation.Point, partyCapacity),
+	}
+	sha3 := keccak.NewSHA3512()
+	sha3.Write(gens.B.RistrettoBytes())
+	gens.BBlinding, _ = new(operation.Point).SetRistrettoUniformBytes(sha3.Sum(nil))
+
+	for j := 0; j < partyCapacity; j++ {
+		label := make([]byte, 5)
+		binary.LittleEndian.PutUint32(label[1:], uint32(j))
+		label[0] = 'G'
+		gens.g[j] = dalekGeneratorsChain(label, gensCapacity)
+		label[0] = 'H'
+		gens.h[j] = dalekGeneratorsChain(label, gensCapacity)
+	}
+	return gens
+}
+
+var (
+	dalekParam     *DalekGens
+	dalekParamOnce sync.Once
+)
+
+// DalekParam returns the generators ExtProve uses, for up to MaxOutputCoin outputs of up to 64 bits.
+// They are derived on first use.
+func DalekParam() *DalekGens {
+	dalekParamOnce.Do(func() {
+		dalekParam = NewDalekGens(MaxExp, MaxOutputCoin)
+	})
+	return dalekParam
+}
+
+// Commit returns the Pedersen commitment value*B + blinding*BBlinding that dalek pairs with a proof
+func (gens DalekGens) Commit(value uint64, blinding *operation.Scalar) *operation.Point {
+	return new(operation.Point).AddPedersen(new(operation.Scalar).FromUint64(value), gens.B, blinding, gens.BBlinding)
+}
+
+// DalekRangeProof is dalek's RangeProof
+type DalekRangeProof struct {
+	a          *operation.Point
+	s          *operation.Point
+	t1         *operation.Point
+	t2         *operation.Point
+	tX         *operation.Scalar
+	tXBlinding *operation.Scalar
+	eBlinding  *operation.Scalar
+	// inner product proof
+	l   []*operation.Point
+	r   []*operation.Point
+	ipA *operation.Scalar
+	ipB *operation.Scalar
+}
+
+// Bytes returns the encoding of RangeProof::to_bytes: A, S, T1, T2, t_x, t_x_blinding, e_blinding,
+// then the pairs L_i, R_i and finally a, b of the inner product proof
+func (proof DalekRangeProof) Bytes() []byte {
+	var res []byte
+	for _, p := range []*operation.Point{proof.a, proof.s, proof.t1, proof.t2} {
+		res = append(res, p.RistrettoBytes()...)
+	}
+	for _, sc := range []*operation.Scalar{proof.tX, proof.tXBlinding, proof.eBlinding} {
+		res = append(res, sc.ToBytesS()...)
+	}
+	for i := range proof.l {
+		res = append(res, proof.l[i].RistrettoBytes()...)
+		res = append(res, proof.r[i].RistrettoBytes()...)
+	}
+	res = append(res, proof.ipA.ToBytesS()...)
+	res = append(res, proof.ipB.ToBytesS()...)
+	return res
+}
+
+// SetBytes parses RangeProof::to_bytes. Unlike dalek, it decodes every point up front.
+func (proof *DalekRangeProof) SetBytes(b []byte) error {
+	if len(b)%operation.Ed25519KeySize != 0 || len(b) < 9*operation.Ed25519KeySize {
+		return errors.New("dalek range proof has an invalid length")
+	}
+	numElements := len(b) / operation.Ed25519KeySize
+	if (numElements-9)%2 != 0 || (numElements-9)/2 >= 32 {
+		return errors.New("dalek range proof has an invalid length")
+	}
+	lgN := (numElements - 9) / 2
+
+	var err error
+	element := func(i int) []byte {
+		return b[i*operation.Ed25519KeySize : (i+1)*operation.Ed25519KeySize]
+	}
+	points := make([]*operation.Point, 4+2*lgN)
+	for i := range points {
+		idx := i
+		if i >= 4 {
+			idx = i + 3
+		}
+		if points[i], err = new(operation.Point).SetRistrettoBytes(element(idx)); err != nil {
+			return err
+		}
+	}
+	scalars := make([]*operation.Scalar, 5)
+	for i, idx := range []int{4, 5, 6, numElements - 2, numElements - 1} {
+		if scalars[i], err = new(operation.Scalar).FromBytesSStrict(element(idx)); err != nil {
+			return err
+		}
+	}
+
+	proof.a, proof.s, proof.t1, proof.t2 = points[0], points[1], points[2], points[3]
+	proof.tX, proof.tXBlinding, proof.eBlinding = scalars[0], scalars[1], scalars[2]
+	proof.l = make([]*operation.Point, lgN)
+	proof.r = make([]*operation.Point, lgN)
+	for i := 0; i < lgN; i++ {
+		proof.l[i], proof.r[i] = points[4+2*i], points[5+2*i]
+	}
+	proof.ipA, proof.ipB = scalars[3], scalars[4]
+	return nil
+}
+
+func dalekAppendPoint(transcript *operation.MerlinTranscript, label string, p *operation.Point) {
+	transcript.AppendMessage([]byte(label), p.RistrettoBytes())
+}
+
+// dalekValidateAndAppendPoint is dalek's validate_and_append_point: it rejects the identity
+func dalekValidateAndAppendPoint(transcript *operation.MerlinTranscript, label string, p *operation.Point) error {
+	encoded := p.RistrettoBytes()
+	if bytes.Equal(encoded, make([]byte, operation.Ed25519KeySize)) {
+		return errors.Errorf("dalek range proof has an identity %s", label)
+	}
+	transcript.AppendMessage([]byte(label), encoded)
+	return nil
+}
+
+// verificationScalars replays the inner product transcript and returns the squared challenges, their inverses
+// squared, and s_i = prod_j u_j^(+-1) for every generator, as InnerProductProof::verification_scalars
+func (proof DalekRangeProof) verificationScalars(n int, transcript *operation.MerlinTranscript) ([]*operation.Scalar, []*operation.Scalar, operation.ScalarVector, error) {
+	lgN := len(proof.l)
+	if n != 1<<uint(lgN) {
+		return nil, nil, nil, errors.New("dalek inner product proof does not match the number of generators")
+	}
+	transcript.AppendMessage([]byte("dom-sep"), []byte("ipp v1"))
+	transcript.AppendUint64([]byte("n"), uint64(n))
+
+	challenges := make([]*operation.Scalar, lgN)
+	for i := range proof.l {
+		if err := dalekValidateAndAppendPoint(transcript, "L", proof.l[i]); err != nil {
+			return nil, nil, nil, err
+		}
+		if err := dalekValidateAndAppendPoint(transcript, "R", proof.r[i]); err != nil {
+			return nil, nil, nil, err
+		}
+		challenges[i] = transcript.ChallengeScalar([]byte("u"))
+	}
+	// the inverse of the product of all challenges is inverted along with them
+	allProduct := new(operation.Scalar).Set(operation.ScOne)
+	for _, u := range challenges {
+		allProduct.Mul(allProduct, u)
+	}
+	inverses, err := batchInverse(append(append([]*operation.Scalar{}, challenges...), allProduct))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	challengesSquare := make([]*operation.Scalar, lgN)
+	challengesInvSquare := make([]*operation.Scalar, lgN)
+	for i := range challenges {
+		challengesSquare[i] = new(operation.Scalar).Mul(challenges[i], challenges[i])
+		challengesInvSquare[i] = new(operation.Scalar).Mul(inverses[i], inverses[i])
+	}
+
+	s := operation.NewScalarVector(n)
+	s[0].Set(inverses[lgN])
+	for i := 1; i < n; i++ {
+		lgI := bits.Len(uint(i)) - 1
+		k := 1 << uint(lgI)
+		// challenges are stored in creation order, so u_(lg(i)+1) is at lgN-1-lgI
+		s[i].Mul(&s[i-k], challengesSquare[lgN-1-lgI])
+	}
+	return challengesSquare, challengesInvSquare, s, nil
+}
+
+// dalekDelta is delta(y, z) = (z - z^2) * <1, y^(n*m)> - z^3 * <1, 2^n> * <1, z^m>
+func dalekDelta(n, m int, y, z *operation.Scalar) *operation.Scalar {
+	sumOfPowers := func(base *operation.Scalar, count int) *operation.Scalar {
+		return powerVector(base, count).InnerProduct(operation.NewScalarVector(count).Fill(operation.ScOne))
+	}
+	zSquare := new(operation.Scalar).Mul(z, z)
+	result := new(operation.Scalar).Sub(z, zSquare)
+	result.Mul(result, sumOfPowers(y, n*m))
+	zCube := new(operation.Scalar).Mul(zSquare, z)
+	zCube.Mul(zCube, sumOfPowers(new(operation.Scalar).FromUint64(2), n))
+	zCube.Mul(zCube, sumOfPowers(z, m))
+	return result.Sub(result, zCube)
+}
+
+// Verify checks a proof that every commitment hides a bitWidth-bit value, as RangeProof::verify_multiple does.
+// transcriptLabel must be the label the prover's Merlin transcript was created with (DalekTranscriptLabel for ExtProve),
+// and the commitments must be given in the prover's order. Their number must be a power of two.
+func (proof DalekRangeProof) Verify(gens *DalekGens, transcriptLabel []byte, commitments []*operation.Point, bitWidth int) (bool, error) {
+	n, m := bitWidth, len(commitments)
+	if !ValidBitWidth(n) {
+		return false, errors.Errorf("invalid range proof bit-width %d", n)
+	}
+	if m == 0 || m&(m-1) != 0 || m > len(gens.g) || n > len(gens.g[0]) {
+		return false, errors.Errorf("cannot verify a dalek range proof for %d outputs of %d bits", m, n)
+	}
+	if proof.a == nil || proof.ipA == nil {
+		return false, errors.New("dalek range proof is empty")
+	}
+
+	transcript := operation.NewMerlinTranscript(transcriptLabel)
+	transcript.AppendMessage([]byte("dom-sep"), []byte("rangeproof v1"))
+	transcript.AppendUint64([]byte("n"), uint64(n))
+	transcript.AppendUint64([]byte("m"), uint64(m))
+	for _, cm := range commitments {
+		dalekAppendPoint(transcript, "V", cm)
+	}
+	if err := dalekValidateAndAppendPoint(transcript, "A", proof.a); err != nil {
+		return false, err
+	}
+	if err := dalekValidateAndAppendPoint(transcript, "S", proof.s); err != nil {
+		return false, err
+	}
+	y := transcript.ChallengeScalar([]byte("y"))
+	z := transcript.ChallengeScalar([]byte("z"))
+	zSquare := new(operation.Scalar).Mul(z, z)
+	zNeg := new(operation.Scalar).Sub(operation.ScZero, z)
+	if err := dalekValidateAndAppendPoint(transcript, "T_1", proof.t1); err != nil {
+		return false, err
+	}
+	if err := dalekValidateAndAppendPoint(transcript, "T_2", proof.t2); err != nil {
+		return false, err
+	}
+	x := transcript.ChallengeScalar([]byte("x"))
+	transcript.AppendMessage([]byte("t_x"), proof.tX.ToBytesS())
+	transcript.AppendMessage([]byte("t_x_blinding"), proof.tXBlinding.ToBytesS())
+	transcript.AppendMessage([]byte("e_blinding"), proof.eBlinding.ToBytesS())
+	w := transcript.ChallengeScalar([]byte("w"))
+	c := operation.RandomScalar()
+
+	xSquare, xInvSquare, s, err := proof.verificationScalars(n*m, transcript)
+	if err != nil {
+		return false, err
+	}
+	yInverse := new(operation.Scalar).Invert(y)
+	yInversePowers := powerVector(yInverse, n*m)
+	twoPowers := powerVector(new(operation.Scalar).FromUint64(2), n)
+
+	// g_i = -z - a*s_i, h_i = z + y^-i * (z^2 * z^j * 2^k - b * s_(N-1-i)) for i = j*n + k
+	gScalars := operation.NewScalarVector(n * m)
+	hScalars := operation.NewScalarVector(n * m)
+	zExp := new(operation.Scalar).Set(operation.ScOne)
+	tmp := new(operation.Scalar)
+	for j := 0; j < m; j++ {
+		zzExp := new(operation.Scalar).Mul(zSquare, zExp)
+		for k := 0; k < n; k++ {
+			i := j*n + k
+			gScalars[i].Mul(proof.ipA, &s[i])
+			gScalars[i].Sub(zNeg, &gScalars[i])
+			tmp.Mul(proof.ipB, &s[n*m-1-i])
+			hScalars[i].Mul(zzExp, &twoPowers[k])
+			hScalars[i].Sub(&hScalars[i], tmp)
+			hScalars[i].MulAdd(&yInversePowers[i], &hScalars[i], z)
+		}
+		zExp.Mul(zExp, z)
+	}
+
+	cx := new(operation.Scalar).Mul(c, x)
+	basepointScalar := new(operation.Scalar).Mul(proof.ipA, proof.ipB)
+	basepointScalar.Sub(proof.tX, basepointScalar)
+	basepointScalar.Mul(basepointScalar, w)
+	basepointScalar.Add(basepointScalar, new(operation.Scalar).Mul(c, new(operation.Scalar).Sub(dalekDelta(n, m, y, z), proof.tX)))
+	blindingScalar := new(operation.Scalar).Mul(c, proof.tXBlinding)
+	blindingScalar.Add(blindingScalar, proof.eBlinding)
+	blindingScalar.Sub(operation.ScZero, blindingScalar)
+
+	builder := NewMSMultBuilder(true).SetWorkers(MSMWorkers)
+	builder.Append([]*operation.Scalar{operation.ScOne, x, cx, new(operation.Scalar).Mul(cx, x), blindingScalar, basepointScalar},
+		[]*operation.Point{proof.a, proof.s, proof.t1, proof.t2, gens.BBlinding, gens.B})
+	builder.Append(xSquare, proof.l)
+	builder.Append(xInvSquare, proof.r)
+	for j := 0; j < m; j++ {
+		builder.Append(gScalars[j*n:(j+1)*n].Ptrs(), gens.g[j][:n])
+		builder.Append(hScalars[j*n:(j+1)*n].Ptrs(), gens.h[j][:n])
+	}
+	commitmentScalars := powerVector(z, m)
+	commitmentScalars.MulScalar(commitmentScalars, new(operation.Scalar).Mul(c, zSquare))
+	builder.Append(commitmentScalars.Ptrs(), commitments)
+
+	if !operation.IsRistrettoEqual(builder.Execute(), operation.NewIdentityPoint()) {
+		Logger.Log.Errorf("verify dalek range proof failed")
+		return false, errors.New("verify dalek range proof failed")
+	}
+	return true, nil
+}
@@ -0,0 +1,211 @@
+package bulletproofs
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dat-incognito-org/newbp/operation"
+)
+
+// JSON encoding of proofs. Every point and scalar is a JSON string holding the hex of its 32-byte encoding
+// (see operation.Point.MarshalJSON and operation.Scalar.MarshalJSON). An AggregatedRangeProof is encoded as
+//
+//	{
+//	  "cmsValue": ["<point>", ...],
+//	  "a": "<point>",
+//	  "s": "<point>",
+//	  "t1": "<point>",
+//	  "t2": "<point>",
+//	  "tauX": "<scalar>",
+//	  "tHat": "<scalar>",
+//	  "mu": "<scalar>",
+//	  "innerProductProof": <InnerProductProof>,
+//	  "bitWidth": 8 | 16 | 32 | 64
+//	}
+//
+// and an InnerProductProof as
+//
+//	{
+//	  "l": ["<point>", ...],
+//	  "r": ["<point>", ...],
+//	  "a": "<scalar>",
+//	  "b": "<scalar>",
+//	  "p": "<point>"
+//	}
+//
+// All fields are required except "bitWidth", which defaults to 64; "l" and "r" must have the same length. Decoding applies the same strict checks as SetBytes.
+// The text form of a proof is the hex of its binary form, which is MarshalBinary().
+
+type rangeProofJSON struct {
+	CmsValue          []*operation.Point `json:"cmsValue"`
+	A                 *operation.Point   `json:"a"`
+	S                 *operation.Point   `json:"s"`
+	T1                *operation.Point   `json:"t1"`
+	T2                *operation.Point   `json:"t2"`
+	TauX              *operation.Scalar  `json:"tauX"`
+	THat              *operation.Scalar  `json:"tHat"`
+	Mu                *operation.Scalar  `json:"mu"`
+	InnerProductProof *InnerProductProof `json:"innerProductProof"`
+	BitWidth          int                `json:"bitWidth,omitempty"`
+}
+
+type innerProductProofJSON struct {
+	L []*operation.Point `json:"l"`
+	R []*operation.Point `json:"r"`
+	A *operation.Scalar  `json:"a"`
+	B *operation.Scalar  `json:"b"`
+	P *operation.Point   `json:"p"`
+}
+
+// MarshalBinary is Bytes, or BytesV2 for a proof with too many commitments for the v1 format
+func (proof AggregatedRangeProof) MarshalBinary() ([]byte, error) {
+	if proof.IsNil() {
+		return nil, fmt.Errorf("cannot marshal an incomplete range proof")
+	}
+	if len(proof.cmsValue) >= bitWidthFlag {
+		return proof.BytesV2(), nil
+	}
+	return proof.Bytes(), nil
+}
+
+// UnmarshalBinary is ParseAggregatedRangeProof, so it reads both wire formats
+func (proof *AggregatedRangeProof) UnmarshalBinary(data []byte) error {
+	parsed, err := ParseAggregatedRangeProof(data)
+	if err != nil {
+		return err
+	}
+	*proof = *parsed
+	return nil
+}
+
+func (proof AggregatedRangeProof) MarshalText() ([]byte, error) {
+	b, err := proof.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (proof *AggregatedRangeProof) UnmarshalText(data []byte) error {
+	b, err := hex.DecodeString(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid range proof hex: %v", err)
+	}
+	return proof.UnmarshalBinary(b)
+}
+
+func (proof AggregatedRangeProof) MarshalJSON() ([]byte, error) {
+	if proof.IsNil() {
+		return nil, fmt.Errorf("cannot marshal an incomplete range proof")
+	}
+	return json.Marshal(rangeProofJSON{
+		CmsValue:          proof.cmsValue,
+		A:                 proof.a,
+		S:                 proof.s,
+		T1:                proof.t1,
+		T2:                proof.t2,
+		TauX:              proof.tauX,
+		THat:              proof.tHat,
+		Mu:                proof.mu,
+		InnerProductProof: proof.innerProductProof,
+		BitWidth:          proof.BitWidth(),
+	})
+}
+
+func (proof *AggregatedRangeProof) UnmarshalJSON(data []byte) error {
+	var temp rangeProofJSON
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+	for _, cm := range temp.CmsValue {
+		if cm == nil {
+			return fmt.Errorf("range proof JSON has a null commitment")
+		}
+	}
+	result := AggregatedRangeProof{
+		cmsValue:          temp.CmsValue,
+		a:                 temp.A,
+		s:                 temp.S,
+		t1:                temp.T1,
+		t2:                temp.T2,
+		tauX:              temp.TauX,
+		tHat:              temp.THat,
+		mu:                temp.Mu,
+		innerProductProof: temp.InnerProductProof,
+		bitWidth:          normalizeBitWidth(temp.BitWidth),
+	}
+	if result.IsNil() {
+		return fmt.Errorf("range proof JSON is missing fields")
+	}
+	if !ValidBitWidth(result.bitWidth) {
+		return fmt.Errorf("range proof JSON has invalid bit-width %d", temp.BitWidth)
+	}
+	*proof = result
+	return nil
+}
+
+func (proof InnerProductProof) MarshalBinary() ([]byte, error) {
+	if proof.a == nil || proof.b == nil || proof.p == nil {
+		return nil, fmt.Errorf("cannot marshal an incomplete inner product proof")
+	}
+	return proof.Bytes(), nil
+}
+
+// UnmarshalBinary is SetBytes, except that it rejects empty input and any byte past the end of the proof
+func (proof *InnerProductProof) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("cannot unmarshal an empty inner product proof")
+	}
+	if err := proof.SetBytes(data); err != nil {
+		return err
+	}
+	// lenL, L, R, a, b, p
+	if len(data) != 1+(2*len(proof.l)+3)*operation.Ed25519KeySize {
+		return fmt.Errorf("inner Product Proof byte unmarshaling failed: unexpected length")
+	}
+	return nil
+}
+
+func (proof InnerProductProof) MarshalText() ([]byte, error) {
+	b, err := proof.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (proof *InnerProductProof) UnmarshalText(data []byte) error {
+	b, err := hex.DecodeString(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid inner product proof hex: %v", err)
+	}
+	return proof.UnmarshalBinary(b)
+}
+
+func (proof InnerProductProof) MarshalJSON() ([]byte, error) {
+	if proof.a == nil || proof.b == nil || proof.p == nil {
+		return nil, fmt.Errorf("cannot marshal an incomplete inner product proof")
+	}
+	return json.Marshal(innerProductProofJSON{L: proof.l, R: proof.r, A: proof.a, B: proof.b, P: proof.p})
+}
+
+func (proof *InnerProductProof) UnmarshalJSON(data []byte) error {
+	var temp innerProductProofJSON
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+	if temp.A == nil || temp.B == nil || temp.P == nil || temp.L == nil || temp.R == nil {
+		return fmt.Errorf("inner product proof JSON is missing fields")
+	}
+	if len(temp.L) != len(temp.R) {
+		return fmt.Errorf("inner product proof JSON has %d l and %d r points", len(temp.L), len(temp.R))
+	}
+	for i := range temp.L {
+		if temp.L[i] == nil || temp.R[i] == nil {
+			return fmt.Errorf("inner product proof JSON has a null point")
+		}
+	}
+	proof.l, proof.r, proof.a, proof.b, proof.p = temp.L, temp.R, temp.A, temp.B, temp.P
+	return nil
+}
@@ -3,6 +3,7 @@
 package bulletproofs
 
 import (
+	"context"
 	"fmt"
 	"math"
 
@@ -10,8 +11,8 @@ import (
 )
 
 type InnerProductWitness struct {
-	a []*operation.Scalar
-	b []*operation.Scalar
+	a operation.ScalarVector
+	b operation.ScalarVector
 	p *operation.Point
 }
 
@@ -41,13 +42,10 @@ func (proof InnerProductProof) ValidateSanity() bool {
 		return false
 	}
 	for i := 0; i < len(proof.l); i++ {
-		if !proof.l[i].PointValid() || !proof.r[i].PointValid() {
+		if !proof.l[i].PointValidNonIdentity() || !proof.r[i].PointValidNonIdentity() {
 			return false
 		}
 	}
-	if !proof.a.ScalarValid() || !proof.b.ScalarValid() {
-		return false
-	}
 	return proof.p.PointValid()
 }
 
@@ -84,7 +82,7 @@ func (proof *InnerProductProof) SetBytes(bytes []byte) error {
 		if offset+operation.Ed25519KeySize > len(bytes) {
 			return fmt.Errorf("inner Product Proof byte unmarshaling failed")
 		}
-		proof.l[i], err = new(operation.Point).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+		proof.l[i], err = new(operation.Point).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
 		if err != nil {
 			return err
 		}
@@ -96,7 +94,7 @@ func (proof *InnerProductProof) SetBytes(bytes []byte) error {
 		if offset+operation.Ed25519KeySize > len(bytes) {
 			return fmt.Errorf("inner Product Proof byte unmarshaling failed")
 		}
-		proof.r[i], err = new(operation.Point).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+		proof.r[i], err = new(operation.Point).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
 		if err != nil {
 			return err
 		}
@@ -106,19 +104,25 @@ func (proof *InnerProductProof) SetBytes(bytes []byte) error {
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return fmt.Errorf("inner Product Proof byte unmarshaling failed")
 	}
-	proof.a = new(operation.Scalar).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.a, err = new(operation.Scalar).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
+	if err != nil {
+		return err
+	}
 	offset += operation.Ed25519KeySize
 
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return fmt.Errorf("inner Product Proof byte unmarshaling failed")
 	}
-	proof.b = new(operation.Scalar).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.b, err = new(operation.Scalar).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
+	if err != nil {
+		return err
+	}
 	offset += operation.Ed25519KeySize
 
 	if offset+operation.Ed25519KeySize > len(bytes) {
 		return fmt.Errorf("inner Product Proof byte unmarshaling failed")
 	}
-	proof.p, err = new(operation.Point).FromBytesS(bytes[offset : offset+operation.Ed25519KeySize])
+	proof.p, err = new(operation.Point).FromBytesSStrict(bytes[offset : offset+operation.Ed25519KeySize])
 	if err != nil {
 		return err
 	}
@@ -127,47 +131,41 @@ func (proof *InnerProductProof) SetBytes(bytes []byte) error {
 }
 
 func (wit InnerProductWitness) Prove(GParam []*operation.Point, HParam []*operation.Point, uParam *operation.Point, hashCache []byte) (*InnerProductProof, error) {
-	if len(wit.a) != len(wit.b) {
+	return wit.prove(context.Background(), GParam, HParam, uParam, hashCache)
+}
+
+// prove stops with ctx.Err() between folding rounds once ctx is done
+func (wit InnerProductWitness) prove(ctx context.Context, GParam []*operation.Point, HParam []*operation.Point, uParam *operation.Point, hashCache []byte) (*InnerProductProof, error) {
+	if len(wit.a) != len(wit.b) || len(GParam) != len(wit.a) || len(HParam) != len(wit.a) {
 		return nil, fmt.Errorf("invalid inputs")
 	}
 
 	N := len(wit.a)
 
-	a := make([]*operation.Scalar, N)
-	b := make([]*operation.Scalar, N)
-
-	for i := range wit.a {
-		a[i] = new(operation.Scalar).Set(wit.a[i])
-		b[i] = new(operation.Scalar).Set(wit.b[i])
-	}
+	// the vectors are folded in place, halving every round
+	a := wit.a.Clone()
+	b := wit.b.Clone()
 
 	p := new(operation.Point).Set(wit.p)
-	G := make([]*operation.Point, N)
-	H := make([]*operation.Point, N)
-	for i := range G {
-		G[i] = new(operation.Point).Set(GParam[i])
-		H[i] = new(operation.Point).Set(HParam[i])
-	}
+	G := operation.PointVectorFromSlice(GParam)
+	H := operation.PointVectorFromSlice(HParam)
 
 	proof := new(InnerProductProof)
 	proof.l = make([]*operation.Point, 0)
 	proof.r = make([]*operation.Point, 0)
 	proof.p = new(operation.Point).Set(wit.p)
 
+	msmBuilder := NewMSMultBuilder(false)
 	for N > 1 {
-		nPrime := N / 2
-
-		cL, err := innerProduct(a[:nPrime], b[nPrime:])
-		if err != nil {
-			return nil, err
-		}
-		cR, err := innerProduct(a[nPrime:], b[:nPrime])
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return nil, err
 		}
+		nPrime := N / 2
 
-		msmBuilder := NewMSMultBuilder(false)
-		msmBuilder, err = encodeVectors(a[:nPrime], b[nPrime:], G[nPrime:], H[:nPrime], msmBuilder)
+		cL := a[:nPrime].InnerProduct(b[nPrime:])
+		cR := a[nPrime:].InnerProduct(b[:nPrime])
+
+		_, err := encodeVectors(a[:nPrime], b[nPrime:], G[nPrime:].Ptrs(), H[:nPrime].Ptrs(), msmBuilder)
 		if err != nil {
 			return nil, err
 		}
@@ -175,7 +173,7 @@ func (wit InnerProductWitness) Prove(GParam []*operation.Point, HParam []*operat
 		L := msmBuilder.Execute()
 		proof.l = append(proof.l, L)
 
-		msmBuilder, err = encodeVectors(a[nPrime:], b[:nPrime], G[:nPrime], H[nPrime:], msmBuilder)
+		_, err = encodeVectors(a[nPrime:], b[:nPrime], G[:nPrime].Ptrs(), H[nPrime:].Ptrs(), msmBuilder)
 		if err != nil {
 			return nil, err
 		}
@@ -191,84 +189,69 @@ func (wit InnerProductWitness) Prove(GParam []*operation.Point, HParam []*operat
 		xSquareInverse := new(operation.Scalar).Mul(xInverse, xInverse)
 
 		// calculate GPrime, HPrime, PPrime for the next loop
-		GPrime := make([]*operation.Point, nPrime)
-		HPrime := make([]*operation.Point, nPrime)
-
-		for i := range GPrime {
-			GPrime[i] = new(operation.Point).AddPedersen(xInverse, G[i], x, G[i+nPrime])
-			HPrime[i] = new(operation.Point).AddPedersen(x, H[i], xInverse, H[i+nPrime])
-		}
+		G = G[:nPrime].Fold(G[:nPrime], G[nPrime:N], xInverse, x)
+		H = H[:nPrime].Fold(H[:nPrime], H[nPrime:N], x, xInverse)
 
 		// x^2 * l + P + xInverse^2 * r
 		PPrime := new(operation.Point).AddPedersen(xSquare, L, xSquareInverse, R)
-		PPrime.Add(PPrime, p)
+		p.Add(PPrime, p)
 
 		// calculate aPrime, bPrime
-		aPrime := make([]*operation.Scalar, nPrime)
-		bPrime := make([]*operation.Scalar, nPrime)
-
-		for i := range aPrime {
-			aPrime[i] = new(operation.Scalar).Mul(a[i], x)
-			aPrime[i] = new(operation.Scalar).MulAdd(a[i+nPrime], xInverse, aPrime[i])
-
-			bPrime[i] = new(operation.Scalar).Mul(b[i], xInverse)
-			bPrime[i] = new(operation.Scalar).MulAdd(b[i+nPrime], x, bPrime[i])
-		}
-
-		a = aPrime
-		b = bPrime
-		p.Set(PPrime)
-		G = GPrime
-		H = HPrime
+		a = a[:nPrime].Fold(a[:nPrime], a[nPrime:N], x, xInverse)
+		b = b[:nPrime].Fold(b[:nPrime], b[nPrime:N], xInverse, x)
 		N = nPrime
 	}
 
-	proof.a = new(operation.Scalar).Set(a[0])
-	proof.b = new(operation.Scalar).Set(b[0])
+	proof.a = new(operation.Scalar).Set(&a[0])
+	proof.b = new(operation.Scalar).Set(&b[0])
 
 	return proof, nil
 }
 func (proof InnerProductProof) Verify(GParam []*operation.Point, HParam []*operation.Point, uParam *operation.Point, hashCache []byte) bool {
+	res, _ := proof.verify(context.Background(), GParam, HParam, uParam, hashCache)
+	return res
+}
+
+// verify stops with ctx.Err() between folding rounds once ctx is done; the error is nil for an invalid proof
+func (proof InnerProductProof) verify(ctx context.Context, GParam []*operation.Point, HParam []*operation.Point, uParam *operation.Point, hashCache []byte) (bool, error) {
 	//var aggParam = newBulletproofParams(1)
 	p := new(operation.Point)
 	p.Set(proof.p)
 
 	n := len(GParam)
-	G := make([]*operation.Point, n)
-	H := make([]*operation.Point, n)
-	for i := range G {
-		G[i] = new(operation.Point).Set(GParam[i])
-		H[i] = new(operation.Point).Set(HParam[i])
+	G := operation.PointVectorFromSlice(GParam)
+	H := operation.PointVectorFromSlice(HParam[:n])
+
+	challenges := innerProductChallenges(hashCache, proof.l, proof.r)
+	challengeInverses, err := batchInverse(challenges)
+	if err != nil {
+		Logger.Log.Error("Inner product argument failed:", err)
+		return false, nil
 	}
 
 	for i := range proof.l {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
 		nPrime := n / 2
-		x := generateChallenge(hashCache, []*operation.Point{proof.l[i], proof.r[i]})
-		hashCache = new(operation.Scalar).Set(x).ToBytesS()
-		xInverse := new(operation.Scalar).Invert(x)
+		x := challenges[i]
+		xInverse := challengeInverses[i]
 		xSquare := new(operation.Scalar).Mul(x, x)
 		xSquareInverse := new(operation.Scalar).Mul(xInverse, xInverse)
 
 		// calculate GPrime, HPrime, PPrime for the next loop
-		GPrime := make([]*operation.Point, nPrime)
-		HPrime := make([]*operation.Point, nPrime)
-
-		for j := 0; j < len(GPrime); j++ {
-			GPrime[j] = new(operation.Point).AddPedersen(xInverse, G[j], x, G[j+nPrime])
-			HPrime[j] = new(operation.Point).AddPedersen(x, H[j], xInverse, H[j+nPrime])
-		}
+		G = G[:nPrime].Fold(G[:nPrime], G[nPrime:2*nPrime], xInverse, x)
+		H = H[:nPrime].Fold(H[:nPrime], H[nPrime:2*nPrime], x, xInverse)
 		// calculate x^2 * l + P + xInverse^2 * r
 		PPrime := new(operation.Point).AddPedersen(xSquare, proof.l[i], xSquareInverse, proof.r[i])
 		PPrime.Add(PPrime, p)
 
 		p = PPrime
-		G = GPrime
-		H = HPrime
 		n = nPrime
 	}
 
 	c := new(operation.Scalar).Mul(proof.a, proof.b)
-	rightPoint := new(operation.Point).AddPedersen(proof.a, G[0], proof.b, H[0])
+	rightPoint := new(operation.Point).AddPedersen(proof.a, &G[0], proof.b, &H[0])
 	rightPoint.Add(rightPoint, new(operation.Point).ScalarMult(uParam, c))
 	res := operation.IsPointEqual(rightPoint, p)
 	if !res {
@@ -277,7 +260,7 @@ func (proof InnerProductProof) Verify(GParam []*operation.Point, HParam []*opera
 		Logger.Log.Error("RightPoint: %v\n", rightPoint)
 	}
 
-	return res
+	return res, nil
 }
 
 //nolint:revive // This function uses underscore in variable name
@@ -286,51 +269,43 @@ func (proof InnerProductProof) VerifyFaster(GParam []*operation.Point, HParam []
 	p := new(operation.Point)
 	p.Set(proof.p)
 	n := len(GParam)
-	G := make([]*operation.Point, n)
-	H := make([]*operation.Point, n)
-	s := make([]*operation.Scalar, n)
-	sInverse := make([]*operation.Scalar, n)
-
-	for i := range G {
-		G[i] = new(operation.Point).Set(GParam[i])
-		H[i] = new(operation.Point).Set(HParam[i])
-		s[i] = new(operation.Scalar).FromUint64(1)
-		sInverse[i] = new(operation.Scalar).FromUint64(1)
-	}
+	one := new(operation.Scalar).FromUint64(1)
+	s := operation.NewScalarVector(n).Fill(one)
+	sInverse := operation.NewScalarVector(n).Fill(one)
 	logN := int(math.Log2(float64(n)))
-	xList := make([]*operation.Scalar, logN)
-	xInverseList := make([]*operation.Scalar, logN)
+	// calculate challenge x = hash(hash(G || H || u || p) || x || l || r)
+	xList := innerProductChallenges(hashCache, proof.l, proof.r)
+	xInverseList, err := batchInverse(xList)
+	if err != nil {
+		Logger.Log.Error("Inner product argument failed:", err)
+		return false
+	}
 	xSquareList := make([]*operation.Scalar, logN)
 	xInverseSquare_List := make([]*operation.Scalar, logN)
 
 	//a*s ; b*s^-1
 
 	for i := range proof.l {
-		// calculate challenge x = hash(hash(G || H || u || p) || x || l || r)
-		xList[i] = generateChallenge(hashCache, []*operation.Point{proof.l[i], proof.r[i]})
-		hashCache = new(operation.Scalar).Set(xList[i]).ToBytesS()
-
-		xInverseList[i] = new(operation.Scalar).Invert(xList[i])
 		xSquareList[i] = new(operation.Scalar).Mul(xList[i], xList[i])
 		xInverseSquare_List[i] = new(operation.Scalar).Mul(xInverseList[i], xInverseList[i])
 
 		//Update s, s^-1
 		for j := 0; j < n; j++ {
 			if j&int(math.Pow(2, float64(logN-i-1))) != 0 {
-				s[j] = new(operation.Scalar).Mul(s[j], xList[i])
-				sInverse[j] = new(operation.Scalar).Mul(sInverse[j], xInverseList[i])
+				s[j].Mul(&s[j], xList[i])
+				sInverse[j].Mul(&sInverse[j], xInverseList[i])
 			} else {
-				s[j] = new(operation.Scalar).Mul(s[j], xInverseList[i])
-				sInverse[j] = new(operation.Scalar).Mul(sInverse[j], xList[i])
+				s[j].Mul(&s[j], xInverseList[i])
+				sInverse[j].Mul(&sInverse[j], xList[i])
 			}
 		}
 	}
 
 	// Compute (g^s)^a (h^-s)^b u^(ab) = p l^(x^2) r^(-x^2)
 	c := new(operation.Scalar).Mul(proof.a, proof.b)
-	rightHSPart1 := new(operation.Point).MultiScalarMult(s, G)
+	rightHSPart1 := new(operation.Point).MultiScalarMult(s.Ptrs(), GParam)
 	rightHSPart1.ScalarMult(rightHSPart1, proof.a)
-	rightHSPart2 := new(operation.Point).MultiScalarMult(sInverse, H)
+	rightHSPart2 := new(operation.Point).MultiScalarMult(sInverse.Ptrs(), HParam[:n])
 	rightHSPart2.ScalarMult(rightHSPart2, proof.b)
 
 	rightHS := new(operation.Point).Add(rightHSPart1, rightHSPart2)
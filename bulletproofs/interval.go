@@ -0,0 +1,134 @@
+package bulletproofs
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/dat-incognito-org/newbp/operation"
+	"github.com/pkg/errors"
+)
+
+// IntervalRangeWitness holds a committed value and its blinder, to prove that min <= value <= max.
+// The proof is an aggregated range proof over the two shifted values value - min and max - value,
+// both proven to fit in the smallest bit-width that holds max - min.
+type IntervalRangeWitness struct {
+	value uint64
+	rand  *operation.Scalar
+	min   uint64
+	max   uint64
+}
+
+// IntervalRangeProof is a range proof for a value in [min, max]. It does not carry any commitment:
+// the verifier derives the shifted commitments from the original commitment and the public bounds.
+// The proof runs over the v2 transcript, with the commitment and the bounds as its context.
+type IntervalRangeProof struct {
+	proof *AggregatedRangeProof
+}
+
+// intervalBitWidth returns the smallest valid bit-width that can hold max - min
+func intervalBitWidth(min, max uint64) int {
+	n := bits.Len64(max - min)
+	for _, bitWidth := range []int{8, 16, 32} {
+		if n <= bitWidth {
+			return bitWidth
+		}
+	}
+	return 64
+}
+
+// intervalCommitments returns the commitments to value - min and max - value, given a commitment cm to value
+func intervalCommitments(cm *operation.Point, min, max uint64) []*operation.Point {
+	g := operation.PedCom.G[operation.PedersenValueIndex]
+	lower := new(operation.Point).Sub(cm, new(operation.Point).ScalarMult(g, new(operation.Scalar).FromUint64(min)))
+	upper := new(operation.Point).Sub(new(operation.Point).ScalarMult(g, new(operation.Scalar).FromUint64(max)), cm)
+	return []*operation.Point{lower, upper}
+}
+
+// intervalTranscript binds cm, min and max into the challenges, so that a proof for one statement cannot be
+// replayed against another that happens to give the same shifted commitments
+func intervalTranscript(cm *operation.Point, min, max uint64) rangeTranscript {
+	context := appendLengthPrefixed(nil, []byte("newbp interval"))
+	context = append(context, cm.ToBytesS()...)
+	var bounds [16]byte
+	binary.BigEndian.PutUint64(bounds[:8], min)
+	binary.BigEndian.PutUint64(bounds[8:], max)
+	return rangeTranscript{v2: true, context: append(context, bounds[:]...)}
+}
+
+func (wit *IntervalRangeWitness) Set(value uint64, rand *operation.Scalar, min, max uint64) error {
+	if min > max {
+		return errors.Errorf("invalid interval [%d, %d]", min, max)
+	}
+	if value < min || value > max {
+		return errors.Errorf("value %d is not in [%d, %d]", value, min, max)
+	}
+	wit.value = value
+	wit.rand = new(operation.Scalar).Set(rand)
+	wit.min = min
+	wit.max = max
+	return nil
+}
+
+// Commitment returns the commitment to the value that the proof is verified against
+func (wit IntervalRangeWitness) Commitment() *operation.Point {
+	return operation.PedCom.CommitAtIndex(new(operation.Scalar).FromUint64(wit.value), wit.rand, operation.PedersenValueIndex)
+}
+
+func (wit IntervalRangeWitness) Prove() (*IntervalRangeProof, error) {
+	return wit.ProveWithRand(nil)
+}
+
+// ProveWithRand is Prove with the blinding values drawn from r, as in AggregatedRangeWitness.ProveWithRand
+func (wit IntervalRangeWitness) ProveWithRand(r operation.RandomSource) (*IntervalRangeProof, error) {
+	if wit.rand == nil {
+		return nil, errors.New("interval range witness is not set")
+	}
+	// value - min is committed with the blinder rand, max - value with -rand
+	aggWit := new(AggregatedRangeWitness)
+	aggWit.Set([]uint64{wit.value - wit.min, wit.max - wit.value}, []*operation.Scalar{wit.rand, new(operation.Scalar).Sub(operation.ScZero, wit.rand)})
+	if err := aggWit.SetBitWidth(intervalBitWidth(wit.min, wit.max)); err != nil {
+		return nil, err
+	}
+	proof, err := aggWit.prove(r, intervalTranscript(wit.Commitment(), wit.min, wit.max))
+	if err != nil {
+		return nil, err
+	}
+	proof.cmsValue = nil
+	return &IntervalRangeProof{proof: proof}, nil
+}
+
+// Verify checks that the value committed in cm lies in [min, max]
+func (proof IntervalRangeProof) Verify(cm *operation.Point, min, max uint64) (bool, error) {
+	if proof.proof == nil || proof.proof.IsNil() {
+		return false, errors.New("interval range proof is empty")
+	}
+	if min > max {
+		return false, errors.Errorf("invalid interval [%d, %d]", min, max)
+	}
+	if bitWidth := intervalBitWidth(min, max); proof.proof.BitWidth() != bitWidth {
+		return false, errors.Errorf("interval [%d, %d] needs a %d-bit proof, got %d bits", min, max, bitWidth, proof.proof.BitWidth())
+	}
+	shifted := *proof.proof
+	shifted.cmsValue = intervalCommitments(cm, min, max)
+	return shifted.verifyFaster(intervalTranscript(cm, min, max))
+}
+
+// Bytes encodes the proof as an AggregatedRangeProof with no commitments
+func (proof IntervalRangeProof) Bytes() []byte {
+	if proof.proof == nil {
+		return []byte{}
+	}
+	return proof.proof.Bytes()
+}
+
+func (proof *IntervalRangeProof) SetBytes(bytes []byte) error {
+	result := new(AggregatedRangeProof)
+	if err := result.SetBytes(bytes); err != nil {
+		return err
+	}
+	if len(result.cmsValue) != 0 {
+		return errors.New("interval range proof must not carry commitments")
+	}
+	proof.proof = result
+	return nil
+}
@@ -0,0 +1,567 @@
+package bulletproofs
+
+import (
+	"fmt"
+
+	"github.com/dat-incognito-org/newbp/operation"
+	"github.com/pkg/errors"
+)
+
+// Multi-party aggregation of an AggregatedRangeProof, after the dealer/party protocol of dalek's range_proof_mpc.
+// Each Party owns one output and the Dealer combines their messages:
+//
+//	party                          dealer
+//	AssignPosition     -- BitCommitment  -->  ReceiveBitCommitments
+//	ApplyBitChallenge  <-- BitChallenge   --
+//	                   -- PolyCommitment -->  ReceivePolyCommitments
+//	ApplyPolyChallenge <-- PolyChallenge  --
+//	                   -- ProofShare     -->  ReceiveShares -> AggregatedRangeProof
+//
+// Party j works on the generators g, h at [j*n, (j+1)*n) and the challenge powers y^(j*n) and z^(j+2),
+// so the sum of the shares is exactly what AggregatedRangeWitness.Prove computes, and the result verifies with Verify.
+// The dealer runs the padding parties itself (value 0, blinding 0) when the party count is not a power of two.
+// A party reveals its blinded vectors l(x) and r(x) to the dealer, as in dalek; it never reveals its value or blinding.
+
+// BitCommitment is a party's commitment to its value and to the bits of its value
+type BitCommitment struct {
+	cmValue *operation.Point
+	a       *operation.Point
+	s       *operation.Point
+}
+
+// BitChallenge is the dealer's reply to the bit commitments
+type BitChallenge struct {
+	y *operation.Scalar
+	z *operation.Scalar
+}
+
+// PolyCommitment is a party's commitment to the coefficients t1, t2 of its share of t(X)
+type PolyCommitment struct {
+	t1 *operation.Point
+	t2 *operation.Point
+}
+
+// PolyChallenge is the dealer's reply to the poly commitments
+type PolyChallenge struct {
+	x *operation.Scalar
+}
+
+// ProofShare is a party's share of the proof: its evaluations of t(x), tauX and mu, and its slices of l(x) and r(x)
+type ProofShare struct {
+	tX   *operation.Scalar
+	tauX *operation.Scalar
+	mu   *operation.Scalar
+	l    operation.ScalarVector
+	r    operation.ScalarVector
+}
+
+// ProofShareError is returned by Dealer.ReceiveShares when some shares do not match their party's commitments
+type ProofShareError struct {
+	// BadShares holds the positions of the misbehaving parties
+	BadShares []int
+}
+
+func (e *ProofShareError) Error() string {
+	return fmt.Sprintf("malformed proof shares from parties %v", e.BadShares)
+}
+
+// mpcState is the round a Party or Dealer is waiting for
+type mpcState int
+
+const (
+	mpcStart mpcState = iota
+	mpcAwaitingBitChallenge
+	mpcAwaitingPolyChallenge
+	mpcDone
+)
+
+// Party holds one output's value and blinder and its secrets between rounds
+type Party struct {
+	state    mpcState
+	value    uint64
+	rand     *operation.Scalar
+	bitWidth int
+	rng      operation.RandomSource
+
+	j       int
+	aL, aR  operation.ScalarVector
+	sL, sR  operation.ScalarVector
+	alpha   *operation.Scalar
+	rho     *operation.Scalar
+	y, z    *operation.Scalar
+	tau1    *operation.Scalar
+	tau2    *operation.Scalar
+	yPowers operation.ScalarVector
+	zOffset *operation.Scalar
+	cmValue *operation.Point
+}
+
+// NewParty creates a party proving that value fits in bitWidth bits, committed with blinder rand
+func NewParty(value uint64, rand *operation.Scalar, bitWidth int) (*Party, error) {
+	return NewPartyWithRand(value, rand, bitWidth, nil)
+}
+
+// NewPartyWithRand is NewParty with the party's blinding values drawn from r, like ProveWithRand
+func NewPartyWithRand(value uint64, rand *operation.Scalar, bitWidth int, r operation.RandomSource) (*Party, error) {
+	if !ValidBitWidth(bitWidth) {
+		return nil, errors.Errorf("invalid range proof bit-width %d", bitWidth)
+	}
+	if bitWidth < 64 && value>>uint(bitWidth) != 0 {
+		return nil, errors.Errorf("value does not fit in %d bits", bitWidth)
+	}
+	if rand == nil {
+		return nil, errors.New("party blinder is nil")
+	}
+	return &Party{value: value, rand: new(operation.Scalar).Set(rand), bitWidth: bitWidth, rng: r}, nil
+}
+
+// AssignPosition places the party at position j of the aggregated proof and returns its bit commitment
+func (p *Party) AssignPosition(j int) (*BitCommitment, error) {
+	if p.state != mpcStart {
+		return nil, errors.New("party has already been assigned a position")
+	}
+	n := p.bitWidth
+	if j < 0 || (j+1)*n > len(AggParam.g) {
+		return nil, errors.Errorf("party position %d is out of range", j)
+	}
+	p.j = j
+	p.aL = operation.NewScalarVector(n)
+	setBits(p.aL, p.value)
+	p.aR = operation.NewScalarVector(n).AddScalar(p.aL, operation.ScMinusOne)
+	var err error
+	if p.sL, err = operation.NewScalarVector(n).RandomFrom(p.rng); err != nil {
+		return nil, err
+	}
+	if p.sR, err = operation.NewScalarVector(n).RandomFrom(p.rng); err != nil {
+		return nil, err
+	}
+	if p.alpha, err = operation.RandomScalarFrom(p.rng); err != nil {
+		return nil, err
+	}
+	if p.rho, err = operation.RandomScalarFrom(p.rng); err != nil {
+		return nil, err
+	}
+
+	// A_j = h^alpha * G_j^aL * H_j^aR, S_j = h^rho * G_j^sL * H_j^sR
+	g, h := AggParam.g[j*n:(j+1)*n], AggParam.h[j*n:(j+1)*n]
+	msmBuilder := NewMSMultBuilder(false)
+	if _, err = encodeVectors(p.aL, p.aR, g, h, msmBuilder); err != nil {
+		return nil, err
+	}
+	msmBuilder.AppendSingle(p.alpha, operation.HBase)
+	a := msmBuilder.Execute()
+	if _, err = encodeVectors(p.sL, p.sR, g, h, msmBuilder); err != nil {
+		return nil, err
+	}
+	msmBuilder.AppendSingle(p.rho, operation.HBase)
+	s := msmBuilder.Execute()
+
+	p.cmValue = operation.PedCom.CommitAtIndex(new(operation.Scalar).FromUint64(p.value), p.rand, operation.PedersenValueIndex)
+	p.state = mpcAwaitingBitChallenge
+	return &BitCommitment{cmValue: p.cmValue, a: a, s: s}, nil
+}
+
+// ApplyBitChallenge computes the party's share of t(X) and returns the commitment to its coefficients
+func (p *Party) ApplyBitChallenge(c *BitChallenge) (*PolyCommitment, error) {
+	if p.state != mpcAwaitingBitChallenge {
+		return nil, errors.New("party is not waiting for a bit challenge")
+	}
+	if c == nil || c.y == nil || c.z == nil || operation.IsScalarEqual(c.y, operation.ScZero) || operation.IsScalarEqual(c.z, operation.ScZero) {
+		return nil, errors.New("invalid bit challenge")
+	}
+	n := p.bitWidth
+	p.y, p.z = new(operation.Scalar).Set(c.y), new(operation.Scalar).Set(c.z)
+	// y^(j*n + i) and z^(j+2)
+	yOffset := powerVector(p.y, p.j*n+1)[p.j*n]
+	p.yPowers = powerVector(p.y, n)
+	p.yPowers.MulScalar(p.yPowers, &yOffset)
+	p.zOffset = new(operation.Scalar).Set(&powerVector(p.z, p.j+3)[p.j+2])
+
+	l0, l1, r0, r1 := p.polynomials()
+	t1 := new(operation.Scalar).Add(l1.InnerProduct(r0), l0.InnerProduct(r1))
+	t2 := l1.InnerProduct(r1)
+	var err error
+	if p.tau1, err = operation.RandomScalarFrom(p.rng); err != nil {
+		return nil, err
+	}
+	if p.tau2, err = operation.RandomScalarFrom(p.rng); err != nil {
+		return nil, err
+	}
+	p.state = mpcAwaitingPolyChallenge
+	return &PolyCommitment{
+		t1: operation.PedCom.CommitAtIndex(t1, p.tau1, operation.PedersenValueIndex),
+		t2: operation.PedCom.CommitAtIndex(t2, p.tau2, operation.PedersenValueIndex),
+	}, nil
+}
+
+// polynomials returns the party's slices of l(X) = l0 + l1*X and r(X) = r0 + r1*X
+func (p *Party) polynomials() (l0, l1, r0, r1 operation.ScalarVector) {
+	n := p.bitWidth
+	l0 = operation.NewScalarVector(n).AddScalar(p.aL, new(operation.Scalar).Sub(operation.ScZero, p.z))
+	l1 = p.sL.Clone()
+	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), n)
+	r0 = operation.NewScalarVector(n).AddScalar(p.aR, p.z)
+	r0.Hadamard(r0, p.yPowers).MulScalarAdd(twoVectorN, p.zOffset, r0)
+	r1 = operation.NewScalarVector(n).Hadamard(p.sR, p.yPowers)
+	return l0, l1, r0, r1
+}
+
+// ApplyPolyChallenge evaluates the party's share of the proof at x
+func (p *Party) ApplyPolyChallenge(c *PolyChallenge) (*ProofShare, error) {
+	if p.state != mpcAwaitingPolyChallenge {
+		return nil, errors.New("party is not waiting for a poly challenge")
+	}
+	// a zero x would reveal l0 and r0, hence the value
+	if c == nil || c.x == nil || operation.IsScalarEqual(c.x, operation.ScZero) {
+		return nil, errors.New("invalid poly challenge")
+	}
+	x := c.x
+	l0, l1, r0, r1 := p.polynomials()
+	share := &ProofShare{
+		l: l0.MulScalarAdd(l1, x, l0),
+		r: r0.MulScalarAdd(r1, x, r0),
+	}
+	share.tX = share.l.InnerProduct(share.r)
+	// tauX = tau2*x^2 + tau1*x + z^(j+2)*rand, mu = alpha + rho*x
+	share.tauX = new(operation.Scalar).Mul(p.tau2, new(operation.Scalar).Mul(x, x))
+	share.tauX.MulAdd(p.tau1, x, share.tauX)
+	share.tauX.MulAdd(p.zOffset, p.rand, share.tauX)
+	share.mu = new(operation.Scalar).MulAdd(p.rho, x, p.alpha)
+	p.state = mpcDone
+	return share, nil
+}
+
+// Dealer collects the parties' messages, issues the challenges and assembles the proof
+type Dealer struct {
+	state       mpcState
+	bitWidth    int
+	numParties  int
+	numValuePad int
+	// padding runs the parties at positions numParties..numValuePad-1
+	padding []*Party
+
+	bitCommitments  []*BitCommitment
+	polyCommitments []*PolyCommitment
+	a, s, t1, t2    *operation.Point
+	y, z, x         *operation.Scalar
+}
+
+// NewDealer creates a dealer for numParties outputs of bitWidth bits
+func NewDealer(bitWidth, numParties int) (*Dealer, error) {
+	if !ValidBitWidth(bitWidth) {
+		return nil, errors.Errorf("invalid range proof bit-width %d", bitWidth)
+	}
+	if numParties < 1 || numParties > MaxOutputCoin {
+		return nil, errors.Errorf("invalid party count %d", numParties)
+	}
+	return &Dealer{bitWidth: bitWidth, numParties: numParties, numValuePad: roundUpPowTwo(numParties)}, nil
+}
+
+// ReceiveBitCommitments takes the bit commitments ordered by party position and returns the bit challenge
+func (d *Dealer) ReceiveBitCommitments(bitCommitments []*BitCommitment) (*BitChallenge, error) {
+	if d.state != mpcStart {
+		return nil, errors.New("dealer is not waiting for bit commitments")
+	}
+	if len(bitCommitments) != d.numParties {
+		return nil, errors.Errorf("expected %d bit commitments, got %d", d.numParties, len(bitCommitments))
+	}
+	all := append([]*BitCommitment{}, bitCommitments...)
+	for j := d.numParties; j < d.numValuePad; j++ {
+		party, err := NewParty(0, operation.ScZero, d.bitWidth)
+		if err != nil {
+			return nil, err
+		}
+		bc, err := party.AssignPosition(j)
+		if err != nil {
+			return nil, err
+		}
+		d.padding = append(d.padding, party)
+		all = append(all, bc)
+	}
+	d.a, d.s = new(operation.Point).Identity(), new(operation.Point).Identity()
+	for j, bc := range all {
+		if bc == nil || bc.cmValue == nil || bc.a == nil || bc.s == nil {
+			return nil, errors.Errorf("bit commitment of party %d is incomplete", j)
+		}
+		d.a.Add(d.a, bc.a)
+		d.s.Add(d.s, bc.s)
+	}
+	d.bitCommitments = all
+
+	N := d.bitWidth * d.numValuePad
+	aggParam := setAggregateParams(N)
+	d.y = generateChallenge(transcriptSeed(aggParam.cs, d.bitWidth), []*operation.Point{d.a, d.s})
+	d.z = generateChallenge(d.y.ToBytesS(), []*operation.Point{d.a, d.s})
+	d.state = mpcAwaitingBitChallenge
+	return &BitChallenge{y: d.y, z: d.z}, nil
+}
+
+// ReceivePolyCommitments takes the poly commitments ordered by party position and returns the poly challenge
+func (d *Dealer) ReceivePolyCommitments(polyCommitments []*PolyCommitment) (*PolyChallenge, error) {
+	if d.state != mpcAwaitingBitChallenge {
+		return nil, errors.New("dealer is not waiting for poly commitments")
+	}
+	if len(polyCommitments) != d.numParties {
+		return nil, errors.Errorf("expected %d poly commitments, got %d", d.numParties, len(polyCommitments))
+	}
+	all := append([]*PolyCommitment{}, polyCommitments...)
+	for _, party := range d.padding {
+		pc, err := party.ApplyBitChallenge(&BitChallenge{y: d.y, z: d.z})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, pc)
+	}
+	d.t1, d.t2 = new(operation.Point).Identity(), new(operation.Point).Identity()
+	for j, pc := range all {
+		if pc == nil || pc.t1 == nil || pc.t2 == nil {
+			return nil, errors.Errorf("poly commitment of party %d is incomplete", j)
+		}
+		d.t1.Add(d.t1, pc.t1)
+		d.t2.Add(d.t2, pc.t2)
+	}
+	d.polyCommitments = all
+	d.x = generateChallenge(d.z.ToBytesS(), []*operation.Point{d.t1, d.t2})
+	d.state = mpcAwaitingPolyChallenge
+	return &PolyChallenge{x: d.x}, nil
+}
+
+// ReceiveShares checks the proof shares, ordered by party position, against the parties' commitments and
+// assembles the aggregated proof. Shares that do not match are reported in a *ProofShareError.
+func (d *Dealer) ReceiveShares(shares []*ProofShare) (*AggregatedRangeProof, error) {
+	if d.state != mpcAwaitingPolyChallenge {
+		return nil, errors.New("dealer is not waiting for proof shares")
+	}
+	if len(shares) != d.numParties {
+		return nil, errors.Errorf("expected %d proof shares, got %d", d.numParties, len(shares))
+	}
+	all := append([]*ProofShare{}, shares...)
+	for _, party := range d.padding {
+		share, err := party.ApplyPolyChallenge(&PolyChallenge{x: d.x})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, share)
+	}
+	var bad []int
+	for j := 0; j < d.numParties; j++ {
+		if !d.auditShare(j, all[j]) {
+			bad = append(bad, j)
+		}
+	}
+	if len(bad) > 0 {
+		return nil, &ProofShareError{BadShares: bad}
+	}
+
+	n := d.bitWidth
+	N := n * d.numValuePad
+	aggParam := setAggregateParams(N)
+	proof := &AggregatedRangeProof{bitWidth: d.bitWidth}
+	proof.cmsValue = make([]*operation.Point, d.numParties)
+	for j := range proof.cmsValue {
+		proof.cmsValue[j] = d.bitCommitments[j].cmValue
+	}
+	proof.a, proof.s, proof.t1, proof.t2 = d.a, d.s, d.t1, d.t2
+	proof.tHat, proof.tauX, proof.mu = new(operation.Scalar).FromUint64(0), new(operation.Scalar).FromUint64(0), new(operation.Scalar).FromUint64(0)
+	lVector := operation.NewScalarVector(N)
+	rVector := operation.NewScalarVector(N)
+	for j, share := range all {
+		proof.tHat.Add(proof.tHat, share.tX)
+		proof.tauX.Add(proof.tauX, share.tauX)
+		proof.mu.Add(proof.mu, share.mu)
+		lVector[j*n : (j+1)*n].Set(share.l)
+		rVector[j*n : (j+1)*n].Set(share.r)
+	}
+
+	// the inner product argument, as in AggregatedRangeWitness.Prove
+	yInverse := new(operation.Scalar).Invert(d.y)
+	HPrime := computeHPrime(yInverse, N, aggParam.h)
+	uPrime := new(operation.Point).ScalarMultTable(aggParam.uTable, operation.HashToScalar(d.x.ToBytesS()))
+	rVectorHPrime := prepareHPrime(yInverse, N)
+	rVectorHPrime.Hadamard(rVector, rVectorHPrime)
+	msmBuilder := NewMSMultBuilder(false)
+	if _, err := encodeVectorsTable(lVector, rVectorHPrime, aggParam.gTable, aggParam.hTable, msmBuilder); err != nil {
+		return nil, err
+	}
+	msmBuilder.AppendSingle(proof.tHat, uPrime)
+	innerProductWit := &InnerProductWitness{a: lVector, b: rVector, p: msmBuilder.Execute()}
+	var err error
+	proof.innerProductProof, err = innerProductWit.Prove(aggParam.g, HPrime.Ptrs(), uPrime, d.x.ToBytesS())
+	if err != nil {
+		return nil, err
+	}
+	d.state = mpcDone
+	return proof, nil
+}
+
+// auditShare checks party j's share against its commitments:
+//
+//	tX = <l, r>
+//	g^tX * h^tauX = V^(z^(j+2)) * g^delta_j * T1^x * T2^(x^2)
+//	A * S^x = h^mu * G^(l + z) * H^(y^-(j*n+i) hada (r - z^(j+2)*2^n) - z)
+func (d *Dealer) auditShare(j int, share *ProofShare) bool {
+	n := d.bitWidth
+	if share == nil || share.tX == nil || share.tauX == nil || share.mu == nil || len(share.l) != n || len(share.r) != n {
+		return false
+	}
+	if !operation.IsScalarEqual(share.tX, share.l.InnerProduct(share.r)) {
+		return false
+	}
+	bc, pc := d.bitCommitments[j], d.polyCommitments[j]
+	x, y, z := d.x, d.y, d.z
+	yPowers := powerVector(y, (j+1)*n)[j*n:]
+	zOffset := new(operation.Scalar).Set(&powerVector(z, j+3)[j+2])
+	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), n)
+
+	// delta_j = (z - z^2) * sum y^(j*n+i) - z^(j+3) * sum 2^i
+	sumY := new(operation.Scalar).FromUint64(0)
+	sumTwo := new(operation.Scalar).FromUint64(0)
+	for i := 0; i < n; i++ {
+		sumY.Add(sumY, &yPowers[i])
+		sumTwo.Add(sumTwo, &twoVectorN[i])
+	}
+	delta := new(operation.Scalar).Mul(new(operation.Scalar).Sub(z, new(operation.Scalar).Mul(z, z)), sumY)
+	delta.Sub(delta, new(operation.Scalar).Mul(new(operation.Scalar).Mul(zOffset, z), sumTwo))
+	builder := NewMSMultBuilder(true)
+	builder.Append([]*operation.Scalar{zOffset, delta, x, new(operation.Scalar).Mul(x, x)},
+		[]*operation.Point{bc.cmValue, operation.PedCom.G[operation.PedersenValueIndex], pc.t1, pc.t2})
+	builder.AppendWithMultiplier([]*operation.Scalar{share.tX, share.tauX},
+		[]*operation.Point{operation.PedCom.G[operation.PedersenValueIndex], operation.PedCom.G[operation.PedersenRandomnessIndex]}, operation.ScMinusOne)
+	if !builder.Execute().IsIdentity() {
+		return false
+	}
+
+	zNeg := new(operation.Scalar).Sub(operation.ScZero, z)
+	gScalars := operation.NewScalarVector(n).AddScalar(share.l, z)
+	hScalars := operation.NewScalarVector(n).MulScalar(twoVectorN, new(operation.Scalar).Sub(operation.ScZero, zOffset))
+	hScalars.Add(hScalars, share.r).Hadamard(hScalars, powerVector(new(operation.Scalar).Invert(y), (j+1)*n)[j*n:]).AddScalar(hScalars, zNeg)
+	if _, err := encodeVectors(gScalars, hScalars, AggParam.g[j*n:(j+1)*n], AggParam.h[j*n:(j+1)*n], builder); err != nil {
+		return false
+	}
+	builder.AppendSingle(share.mu, operation.HBase)
+	builder.AppendWithMultiplier([]*operation.Scalar{operation.ScOne, x}, []*operation.Point{bc.a, bc.s}, operation.ScMinusOne)
+	return builder.Execute().IsIdentity()
+}
+
+// Bytes encodes the bit commitment as V || A || S
+func (bc BitCommitment) Bytes() []byte {
+	return appendPoints(nil, bc.cmValue, bc.a, bc.s)
+}
+
+func (bc *BitCommitment) SetBytes(b []byte) error {
+	points, err := readFixedPoints(b, 3)
+	if err != nil {
+		return errors.Wrap(err, "invalid bit commitment")
+	}
+	bc.cmValue, bc.a, bc.s = points[0], points[1], points[2]
+	return nil
+}
+
+// Bytes encodes the bit challenge as y || z
+func (c BitChallenge) Bytes() []byte {
+	return appendScalars(nil, c.y, c.z)
+}
+
+func (c *BitChallenge) SetBytes(b []byte) error {
+	scalars, err := readFixedScalars(b, 2)
+	if err != nil {
+		return errors.Wrap(err, "invalid bit challenge")
+	}
+	c.y, c.z = scalars[0], scalars[1]
+	return nil
+}
+
+// Bytes encodes the poly commitment as T1 || T2
+func (pc PolyCommitment) Bytes() []byte {
+	return appendPoints(nil, pc.t1, pc.t2)
+}
+
+func (pc *PolyCommitment) SetBytes(b []byte) error {
+	points, err := readFixedPoints(b, 2)
+	if err != nil {
+		return errors.Wrap(err, "invalid poly commitment")
+	}
+	pc.t1, pc.t2 = points[0], points[1]
+	return nil
+}
+
+// Bytes encodes the poly challenge as x
+func (c PolyChallenge) Bytes() []byte {
+	return appendScalars(nil, c.x)
+}
+
+func (c *PolyChallenge) SetBytes(b []byte) error {
+	scalars, err := readFixedScalars(b, 1)
+	if err != nil {
+		return errors.Wrap(err, "invalid poly challenge")
+	}
+	c.x = scalars[0]
+	return nil
+}
+
+// Bytes encodes the proof share as tX || tauX || mu || l || r; the bit-width is implied by the length
+func (share ProofShare) Bytes() []byte {
+	res := appendScalars(nil, share.tX, share.tauX, share.mu)
+	res = appendScalars(res, share.l.Ptrs()...)
+	return appendScalars(res, share.r.Ptrs()...)
+}
+
+func (share *ProofShare) SetBytes(b []byte) error {
+	count := len(b) / operation.Ed25519KeySize
+	n := (count - 3) / 2
+	if count < 3 || (count-3)%2 != 0 || !ValidBitWidth(n) {
+		return errors.New("invalid proof share length")
+	}
+	scalars, err := readFixedScalars(b, count)
+	if err != nil {
+		return errors.Wrap(err, "invalid proof share")
+	}
+	share.tX, share.tauX, share.mu = scalars[0], scalars[1], scalars[2]
+	share.l = operation.ScalarVectorFromSlice(scalars[3 : 3+n])
+	share.r = operation.ScalarVectorFromSlice(scalars[3+n:])
+	return nil
+}
+
+func appendPoints(dst []byte, points ...*operation.Point) []byte {
+	for _, p := range points {
+		dst = append(dst, p.ToBytesS()...)
+	}
+	return dst
+}
+
+func appendScalars(dst []byte, scalars ...*operation.Scalar) []byte {
+	for _, sc := range scalars {
+		dst = append(dst, sc.ToBytesS()...)
+	}
+	return dst
+}
+
+// readFixedPoints decodes exactly count points from b
+func readFixedPoints(b []byte, count int) ([]*operation.Point, error) {
+	if len(b) != count*operation.Ed25519KeySize {
+		return nil, errors.Errorf("expected %d bytes, got %d", count*operation.Ed25519KeySize, len(b))
+	}
+	points := make([]*operation.Point, count)
+	var err error
+	for i := range points {
+		if points[i], err = new(operation.Point).FromBytesSStrict(b[i*operation.Ed25519KeySize : (i+1)*operation.Ed25519KeySize]); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+// readFixedScalars decodes exactly count canonical scalars from b
+func readFixedScalars(b []byte, count int) ([]*operation.Scalar, error) {
+	if len(b) != count*operation.Ed25519KeySize {
+		return nil, errors.Errorf("expected %d bytes, got %d", count*operation.Ed25519KeySize, len(b))
+	}
+	scalars := make([]*operation.Scalar, count)
+	var err error
+	for i := range scalars {
+		if scalars[i], err = new(operation.Scalar).FromBytesSStrict(b[i*operation.Ed25519KeySize : (i+1)*operation.Ed25519KeySize]); err != nil {
+			return nil, err
+		}
+	}
+	return scalars, nil
+}
@@ -0,0 +1,101 @@
+package bulletproofs
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+
+	"github.com/dat-incognito-org/newbp/operation"
+)
+
+// nonceDomain separates the deterministic nonce seed from every other hash in the package
+const nonceDomain = "newbp/bulletproofs/nonce/v1"
+
+// hmacDRBG is HMAC_DRBG with SHA-512 (NIST SP 800-90A), the generator RFC 6979 builds its nonces from.
+// It never reseeds; it only serves as the RandomSource of a single deterministic proof.
+type hmacDRBG struct {
+	k []byte
+	v []byte
+}
+
+func newHMACDRBG(seed []byte) *hmacDRBG {
+	d := &hmacDRBG{
+		k: make([]byte, sha512.Size),
+		v: make([]byte, sha512.Size),
+	}
+	for i := range d.v {
+		d.v[i] = 0x01
+	}
+	d.update(seed)
+	return d
+}
+
+func (d *hmacDRBG) hmac(data ...[]byte) []byte {
+	h := hmac.New(sha512.New, d.k)
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+func (d *hmacDRBG) update(seed []byte) {
+	d.k = d.hmac(d.v, []byte{0x00}, seed)
+	d.v = d.hmac(d.v)
+	if len(seed) == 0 {
+		return
+	}
+	d.k = d.hmac(d.v, []byte{0x01}, seed)
+	d.v = d.hmac(d.v)
+}
+
+// Read fills p with the next bytes of the stream; it never fails
+func (d *hmacDRBG) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		d.v = d.hmac(d.v)
+		n += copy(p[n:], d.v)
+	}
+	d.update(nil)
+	return len(p), nil
+}
+
+func appendLengthPrefixed(dst, b []byte) []byte {
+	var l [8]byte
+	binary.LittleEndian.PutUint64(l[:], uint64(len(b)))
+	return append(append(dst, l[:]...), b...)
+}
+
+// nonceSource seeds a DRBG with the whole witness, the message and the extra entropy, each length-prefixed,
+// so that changing any of them gives unrelated nonces
+func (wit AggregatedRangeWitness) nonceSource(base *operation.Point, msg, extraEntropy []byte) operation.RandomSource {
+	seed := appendLengthPrefixed(nil, []byte(nonceDomain))
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], uint64(normalizeBitWidth(wit.bitWidth)))
+	seed = append(seed, tmp[:]...)
+	binary.LittleEndian.PutUint64(tmp[:], uint64(len(wit.values)))
+	seed = append(seed, tmp[:]...)
+	for i := range wit.values {
+		binary.LittleEndian.PutUint64(tmp[:], wit.values[i])
+		seed = append(seed, tmp[:]...)
+		seed = append(seed, wit.rands[i].ToBytesS()...)
+	}
+	var baseBytes []byte
+	if base != nil {
+		baseBytes = base.ToBytesS()
+	}
+	seed = appendLengthPrefixed(seed, baseBytes)
+	seed = appendLengthPrefixed(seed, msg)
+	seed = appendLengthPrefixed(seed, extraEntropy)
+	return newHMACDRBG(seed)
+}
+
+// ProveDeterministic creates a range proof whose blinding values are derived from the witness, msg and the optional
+// extraEntropy, in the manner of RFC 6979. The same inputs always give the same proof bytes, and no RNG is needed.
+// msg should identify what the proof is for (e.g. the transaction being built), so unrelated proofs never share nonces.
+func (wit AggregatedRangeWitness) ProveDeterministic(msg, extraEntropy []byte) (*AggregatedRangeProof, error) {
+	return wit.ProveWithRand(wit.nonceSource(nil, msg, extraEntropy))
+}
+
+// ProveUsingBaseDeterministic is ProveDeterministic for ProveUsingBase; the asset tag is part of the nonce seed
+func (wit AggregatedRangeWitness) ProveUsingBaseDeterministic(anAssetTag *operation.Point, msg, extraEntropy []byte) (*AggregatedRangeProof, error) {
+	return wit.ProveUsingBaseWithRand(anAssetTag, wit.nonceSource(anAssetTag, msg, extraEntropy))
+}
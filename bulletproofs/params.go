@@ -0,0 +1,548 @@
+package bulletproofs
+
+import (
+	"context"
+	"math"
+
+	"github.com/dat-incognito-org/newbp/operation"
+	"github.com/pkg/errors"
+)
+
+// RangeProofParams is the set of public parameters an AggregatedRangeProof is made over: the Pedersen value base G
+// and blinding base H of the commitments V = G^v * H^r, the vectors g, h of the vector commitments, the inner product
+// base u and the transcript seed cs. H also blinds A, S and the inner product argument.
+// Prove, Verify and VerifyFaster use DefaultRangeProofParams; the CA variants use it WithValueBase(assetTag).
+type RangeProofParams struct {
+	valueBase    *operation.Point
+	blindingBase *operation.Point
+	g            []*operation.Point
+	h            []*operation.Point
+	u            *operation.Point
+	cs           *operation.Point
+	// valueTable is nil for value bases without a precomputed table, such as asset tags
+	valueTable    *operation.FixedBaseTable
+	blindingTable *operation.FixedBaseTable
+	uTable        *operation.FixedBaseTable
+	gTable        *operation.MSMTable
+	hTable        *operation.MSMTable
+}
+
+var defaultRangeProofParams = &RangeProofParams{
+	valueBase:     operation.PedCom.G[operation.PedersenValueIndex],
+	blindingBase:  operation.PedCom.G[operation.PedersenRandomnessIndex],
+	g:             AggParam.g,
+	h:             AggParam.h,
+	u:             AggParam.u,
+	cs:            AggParam.cs,
+	valueTable:    operation.GBaseTable,
+	blindingTable: operation.HBaseTable,
+	uTable:        AggParam.uTable,
+	gTable:        AggParam.gTable,
+	hTable:        AggParam.hTable,
+}
+
+// DefaultRangeProofParams returns the parameters of PRV range proofs: PedCom's value and randomness bases and AggParam
+func DefaultRangeProofParams() *RangeProofParams {
+	return defaultRangeProofParams
+}
+
+// NewRangeProofParams builds a parameter set from its generators and precomputes their tables. g and h must have the
+// same length, which bounds bitWidth times the padded output count of the proofs made over it.
+func NewRangeProofParams(valueBase, blindingBase *operation.Point, g, h []*operation.Point, u, cs *operation.Point) (*RangeProofParams, error) {
+	if valueBase == nil || blindingBase == nil || u == nil || cs == nil {
+		return nil, errors.New("range proof params: nil generator")
+	}
+	if len(g) == 0 || len(g) != len(h) {
+		return nil, errors.Errorf("range proof params: got %d g and %d h generators", len(g), len(h))
+	}
+	for i := range g {
+		if g[i] == nil || h[i] == nil {
+			return nil, errors.New("range proof params: nil generator")
+		}
+	}
+	return &RangeProofParams{
+		valueBase:     new(operation.Point).Set(valueBase),
+		blindingBase:  new(operation.Point).Set(blindingBase),
+		g:             operation.PointVectorFromSlice(g).Ptrs(),
+		h:             operation.PointVectorFromSlice(h).Ptrs(),
+		u:             new(operation.Point).Set(u),
+		cs:            new(operation.Point).Set(cs),
+		valueTable:    operation.NewFixedBaseTable(valueBase),
+		blindingTable: operation.NewFixedBaseTable(blindingBase),
+		uTable:        operation.NewFixedBaseTable(u),
+		gTable:        operation.NewMSMTable(g),
+		hTable:        operation.NewMSMTable(h),
+	}, nil
+}
+
+// NewRangeProofParamsWithDerivation builds the parameters for up to m outputs of DefaultBitWidth from the generators
+// of the given derivation scheme, e.g. for a network that does not use the legacy generators
+func NewRangeProofParamsWithDerivation(version operation.GeneratorDerivation, m int) (*RangeProofParams, error) {
+	pedCom, err := operation.NewPedersenParamsWithDerivation(version)
+	if err != nil {
+		return nil, err
+	}
+	param, err := newBulletproofParamsWithDerivation(m, version)
+	if err != nil {
+		return nil, err
+	}
+	return NewRangeProofParams(pedCom.G[operation.PedersenValueIndex], pedCom.G[operation.PedersenRandomnessIndex], param.g, param.h, param.u, param.cs)
+}
+
+// WithValueBase returns a copy of params that commits values to valueBase, such as a CA asset tag.
+// The other generators and their tables are shared.
+func (params *RangeProofParams) WithValueBase(valueBase *operation.Point) *RangeProofParams {
+	result := *params
+	result.valueBase = new(operation.Point).Set(valueBase)
+	result.valueTable = nil
+	return &result
+}
+
+func (params *RangeProofParams) ValueBase() *operation.Point {
+	return new(operation.Point).Set(params.valueBase)
+}
+
+func (params *RangeProofParams) BlindingBase() *operation.Point {
+	return new(operation.Point).Set(params.blindingBase)
+}
+
+// Capacity returns the number of g, h generators, the largest bitWidth * padded output count a proof may use
+func (params *RangeProofParams) Capacity() int {
+	return len(params.g)
+}
+
+// Commit returns G^value * H^rand
+func (params *RangeProofParams) Commit(value, rand *operation.Scalar) *operation.Point {
+	if params.valueTable != nil {
+		return new(operation.Point).AddPedersenTable(value, params.valueTable, rand, params.blindingTable)
+	}
+	return new(operation.Point).AddPedersen(value, params.valueBase, rand, params.blindingBase)
+}
+
+// checkCapacity fails if a proof of N generators does not fit in params
+func (params *RangeProofParams) checkCapacity(N int) error {
+	if N > len(params.g) {
+		return errors.Errorf("range proof needs %d generators, params have %d", N, len(params.g))
+	}
+	return nil
+}
+
+// Prove creates a range proof for wit over params, using crypto/rand for the blinding values
+func (params *RangeProofParams) Prove(wit *AggregatedRangeWitness) (*AggregatedRangeProof, error) {
+	return params.prove(context.Background(), wit, nil, legacyTranscript, nil)
+}
+
+// ProveWithRand is Prove with the blinding values drawn from r
+func (params *RangeProofParams) ProveWithRand(wit *AggregatedRangeWitness, r operation.RandomSource) (*AggregatedRangeProof, error) {
+	return params.prove(context.Background(), wit, r, legacyTranscript, nil)
+}
+
+// ProveContext is Prove that stops with ctx.Err() once ctx is done. It is checked between the rounds of the protocol
+// and between the folding rounds of the inner product argument.
+func (params *RangeProofParams) ProveContext(ctx context.Context, wit *AggregatedRangeWitness) (*AggregatedRangeProof, error) {
+	return params.prove(ctx, wit, nil, legacyTranscript, nil)
+}
+
+// prove takes its N-sized scalar vectors from scratch, which may be nil
+func (params *RangeProofParams) prove(ctx context.Context, wit *AggregatedRangeWitness, r operation.RandomSource, tr rangeTranscript, scratch *proverScratch) (*AggregatedRangeProof, error) {
+	proof := new(AggregatedRangeProof)
+	numValue := len(wit.values)
+	if numValue > MaxOutputCoin {
+		return nil, errors.New("Must less than MaxOutputCoin")
+	}
+	maxExp, err := wit.checkBitWidth()
+	if err != nil {
+		return nil, err
+	}
+	proof.bitWidth = maxExp
+	numValuePad := roundUpPowTwo(numValue)
+	N := maxExp * numValuePad
+	if err = params.checkCapacity(N); err != nil {
+		return nil, err
+	}
+
+	values := make([]uint64, numValuePad)
+	rands := make([]*operation.Scalar, numValuePad)
+	for i := range wit.values {
+		values[i] = wit.values[i]
+		rands[i] = new(operation.Scalar).Set(wit.rands[i])
+	}
+	for i := numValue; i < numValuePad; i++ {
+		values[i] = uint64(0)
+		rands[i] = new(operation.Scalar).FromUint64(0)
+	}
+
+	proof.cmsValue = make([]*operation.Point, numValue)
+	for i := 0; i < numValue; i++ {
+		proof.cmsValue[i] = params.Commit(new(operation.Scalar).FromUint64(values[i]), rands[i])
+	}
+	// Convert values to binary array
+	aL := scratch.vector(scratchAL, N)
+	aR := scratch.vector(scratchAR, N)
+	sL, err := scratch.vector(scratchSL, N).RandomFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	sR, err := scratch.vector(scratchSR, N).RandomFrom(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		setBits(aL[i*maxExp:(i+1)*maxExp], value)
+	}
+	aR.AddScalar(aL, operation.ScMinusOne)
+	// LINE 40-50
+	// Commitment to aL, aR: A = h^alpha * G^aL * H^aR
+	// Commitment to sL, sR : S = h^rho * G^sL * H^sR
+	alpha, err := operation.RandomScalarFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	rho, err := operation.RandomScalarFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	msmBuilder := NewMSMultBuilder(false)
+	_, err = encodeVectorsTable(aL, aR, params.gTable, params.hTable, msmBuilder)
+	if err != nil {
+		return nil, err
+	}
+	msmBuilder.AppendSingle(alpha, params.blindingBase)
+	proof.a = msmBuilder.Execute()
+
+	_, err = encodeVectorsTable(sL, sR, params.gTable, params.hTable, msmBuilder)
+	if err != nil {
+		return nil, err
+	}
+	msmBuilder.AppendSingle(rho, params.blindingBase)
+	proof.s = msmBuilder.Execute()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	// challenge y, z
+	y := generateChallenge(tr.seed(params.cs, maxExp, proof.cmsValue), []*operation.Point{proof.a, proof.s})
+	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a, proof.s})
+
+	// LINE 51-54
+	twoNumber := new(operation.Scalar).FromUint64(2)
+	twoVectorN := powerVector(twoNumber, maxExp)
+
+	// HPrime = H^(y^(1-i)
+	yInverse := new(operation.Scalar).Invert(y)
+	HPrime := computeHPrime(yInverse, N, params.h)
+
+	// l(X) = (aL -z*1^n) + sL*X; r(X) = y^n hada (aR +z*1^n + sR*X) + z^2 * 2^n
+	yVector := scratch.vector(scratchY, N).Powers(y)
+	vectorSum := scratch.vector(scratchSum, N)
+	zTmp := new(operation.Scalar).Set(z)
+	for j := 0; j < numValuePad; j++ {
+		zTmp.Mul(zTmp, z)
+		vectorSum[j*maxExp:(j+1)*maxExp].MulScalar(twoVectorN, zTmp)
+	}
+	zNeg := new(operation.Scalar).Sub(new(operation.Scalar).FromUint64(0), z)
+	l0 := scratch.vector(scratchL0, N).AddScalar(aL, zNeg)
+	l1 := sL
+	r0 := scratch.vector(scratchR0, N).AddScalar(aR, z)
+	r0.Hadamard(yVector, r0).Add(r0, vectorSum)
+	r1 := scratch.vector(scratchR1, N).Hadamard(yVector, sR)
+
+	// t(X) = <l(X), r(X)> = t0 + t1*X + t2*X^2
+	// t1 = <l1, ro> + <l0, r1>, t2 = <l1, r1>
+	t1 := new(operation.Scalar).Add(l1.InnerProduct(r0), l0.InnerProduct(r1))
+	t2 := l1.InnerProduct(r1)
+
+	// commitment to t1, t2
+	tau1, err := operation.RandomScalarFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	tau2, err := operation.RandomScalarFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	proof.t1 = params.Commit(t1, tau1)
+	proof.t2 = params.Commit(t2, tau2)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	x := generateChallenge(z.ToBytesS(), []*operation.Point{proof.t1, proof.t2})
+	xSquare := new(operation.Scalar).Mul(x, x)
+
+	// lVector = aL - z*1^n + sL*x = l0 + l1*x
+	// rVector = y^n hada (aR +z*1^n + sR*x) + z^2*2^n = r0 + r1*x
+	// tHat = <lVector, rVector>
+	// l0 and r0 are not needed any more, so they are reused
+	lVector := l0.MulScalarAdd(l1, x, l0)
+	rVector := r0.MulScalarAdd(r1, x, r0)
+	proof.tHat = lVector.InnerProduct(rVector)
+
+	// blinding value for tHat: tauX = tau2*x^2 + tau1*x + z^2*rand
+	proof.tauX = new(operation.Scalar).Mul(tau2, xSquare)
+	proof.tauX.Add(proof.tauX, new(operation.Scalar).Mul(tau1, x))
+	zTmp = new(operation.Scalar).Set(z)
+	tmpBN := new(operation.Scalar)
+	for j := 0; j < numValuePad; j++ {
+		zTmp.Mul(zTmp, z)
+		proof.tauX.Add(proof.tauX, tmpBN.Mul(zTmp, rands[j]))
+	}
+
+	// alpha, rho blind A, S
+	// mu = alpha + rho*x
+	proof.mu = new(operation.Scalar).Add(alpha, new(operation.Scalar).Mul(rho, x))
+
+	// instead of sending left vector and right vector, we use inner sum argument to reduce proof size from 2*n to 2(log2(n)) + 2
+	innerProductWit := new(InnerProductWitness)
+	innerProductWit.a = lVector
+	innerProductWit.b = rVector
+	uPrime := new(operation.Point).ScalarMultTable(params.uTable, operation.HashToScalar(x.ToBytesS()))
+
+	// HPrime^rVector = H^(rVector hada y^(-n)), which lets the static table of H be used
+	rVectorHPrime := scratch.vector(scratchHPrime, N).Powers(yInverse)
+	rVectorHPrime.Hadamard(rVector, rVectorHPrime)
+	_, err = encodeVectorsTable(lVector, rVectorHPrime, params.gTable, params.hTable, msmBuilder)
+	if err != nil {
+		return nil, err
+	}
+	msmBuilder.AppendSingle(proof.tHat, uPrime)
+	innerProductWit.p = msmBuilder.Execute()
+
+	proof.innerProductProof, err = innerProductWit.prove(ctx, params.g[:N], HPrime.Ptrs(), uPrime, x.ToBytesS())
+	if err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// Verify checks each statement of proof separately over params
+func (params *RangeProofParams) Verify(proof *AggregatedRangeProof) (bool, error) {
+	return params.verify(context.Background(), proof, legacyTranscript)
+}
+
+// VerifyContext is Verify that stops with ctx.Err() once ctx is done, checked like in ProveContext
+func (params *RangeProofParams) VerifyContext(ctx context.Context, proof *AggregatedRangeProof) (bool, error) {
+	return params.verify(ctx, proof, legacyTranscript)
+}
+
+func (params *RangeProofParams) verify(ctx context.Context, proof *AggregatedRangeProof, tr rangeTranscript) (bool, error) {
+	if proof == nil || proof.IsNil() {
+		return false, errors.New("range proof is incomplete")
+	}
+	numValue := len(proof.cmsValue)
+	maxExp, numValuePad, N, err := proof.dimensions()
+	if err != nil {
+		return false, err
+	}
+	if err = params.checkCapacity(N); err != nil {
+		return false, err
+	}
+	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), maxExp)
+	g := params.g[:N]
+
+	cmsValue := proof.cmsValue
+	for i := numValue; i < numValuePad; i++ {
+		cmsValue = append(cmsValue, new(operation.Point).Identity())
+	}
+
+	// recalculate challenge y, z
+	y := generateChallenge(tr.seed(params.cs, maxExp, proof.cmsValue), []*operation.Point{proof.a, proof.s})
+	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a, proof.s})
+	zSquare := new(operation.Scalar).Mul(z, z)
+	zNeg := new(operation.Scalar).Sub(new(operation.Scalar).FromUint64(0), z)
+
+	x := generateChallenge(z.ToBytesS(), []*operation.Point{proof.t1, proof.t2})
+	xSquare := new(operation.Scalar).Mul(x, x)
+
+	// HPrime = H^(y^(1-i)
+	HPrime := computeHPrime(new(operation.Scalar).Invert(y), N, params.h)
+
+	// g^tHat * h^tauX = V^(z^2) * g^delta(y,z) * T1^x * T2^(x^2)
+	yVector := powerVector(y, N)
+	deltaYZ := computeDeltaYZ(z, zSquare, yVector, N, maxExp)
+
+	LHS := params.Commit(proof.tHat, proof.tauX)
+	RHS := new(operation.Point).ScalarMult(proof.t2, xSquare)
+	RHS.Add(RHS, operation.NewIdentityPoint().AddPedersen(deltaYZ, params.valueBase, x, proof.t1))
+
+	expVector := powerVector(z, numValuePad)
+	expVector.MulScalar(expVector, zSquare)
+	RHS.Add(RHS, new(operation.Point).VarTimeMultiScalarMult(expVector.Ptrs(), cmsValue))
+
+	if !operation.IsPointEqual(LHS, RHS) {
+		Logger.Log.Errorf("verify aggregated range proof statement 1 failed")
+		return false, errors.New("verify aggregated range proof statement 1 failed")
+	}
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+
+	// verify eq (66)
+	uPrime := new(operation.Point).ScalarMultTable(params.uTable, operation.HashToScalar(x.ToBytesS()))
+
+	vectorSum := operation.NewScalarVector(N)
+	zTmp := new(operation.Scalar).Set(z)
+	for j := 0; j < numValuePad; j++ {
+		zTmp.Mul(zTmp, z)
+		vectorSum[j*maxExp:(j+1)*maxExp].MulScalar(twoVectorN, zTmp)
+	}
+	vectorSum.MulScalarAdd(yVector, z, vectorSum)
+	tmpHPrime := new(operation.Point).VarTimeMultiScalarMult(vectorSum.Ptrs(), HPrime.Ptrs())
+	tmpG := new(operation.Point).Set(g[0])
+	for i := 1; i < N; i++ {
+		tmpG.Add(tmpG, g[i])
+	}
+
+	ASx := new(operation.Point).Add(proof.a, new(operation.Point).ScalarMult(proof.s, x))
+	P := new(operation.Point).Add(new(operation.Point).ScalarMult(tmpG, zNeg), tmpHPrime)
+	P.Add(P, ASx)
+	P.Add(P, new(operation.Point).ScalarMult(uPrime, proof.tHat))
+	PPrime := new(operation.Point).Add(proof.innerProductProof.p, new(operation.Point).ScalarMultTable(params.blindingTable, proof.mu))
+	if !operation.IsPointEqual(P, PPrime) {
+		Logger.Log.Errorf("verify aggregated range proof statement 2-1 failed")
+		return false, errors.New("verify aggregated range proof statement 2-1 failed")
+	}
+
+	// verify eq (68)
+	innerProductArgValid, err := proof.innerProductProof.verify(ctx, g, HPrime.Ptrs(), uPrime, x.ToBytesS())
+	if err != nil {
+		return false, err
+	}
+	if !innerProductArgValid {
+		Logger.Log.Errorf("verify aggregated range proof statement 2 failed")
+		return false, errors.New("verify aggregated range proof statement 2 failed")
+	}
+
+	return true, nil
+}
+
+// VerifyFaster checks all statements of proof over params with a single multi-scalar multiplication
+func (params *RangeProofParams) VerifyFaster(proof *AggregatedRangeProof) (bool, error) {
+	return params.verifyFaster(proof, legacyTranscript)
+}
+
+func (params *RangeProofParams) verifyFaster(proof *AggregatedRangeProof, tr rangeTranscript) (bool, error) {
+	if proof == nil || proof.IsNil() {
+		return false, errors.New("range proof is incomplete")
+	}
+	numValue := len(proof.cmsValue)
+	maxExp, numValuePad, N, err := proof.dimensions()
+	if err != nil {
+		return false, err
+	}
+	if err = params.checkCapacity(N); err != nil {
+		return false, err
+	}
+	twoVectorN := powerVector(new(operation.Scalar).FromUint64(2), maxExp)
+
+	cmsValue := proof.cmsValue
+	for i := numValue; i < numValuePad; i++ {
+		cmsValue = append(cmsValue, new(operation.Point).Identity())
+	}
+
+	// recalculate challenge y, z
+	y := generateChallenge(tr.seed(params.cs, maxExp, proof.cmsValue), []*operation.Point{proof.a, proof.s})
+	z := generateChallenge(y.ToBytesS(), []*operation.Point{proof.a, proof.s})
+	zSquare := new(operation.Scalar).Mul(z, z)
+	zNeg := new(operation.Scalar).Sub(new(operation.Scalar).FromUint64(0), z)
+
+	x := generateChallenge(z.ToBytesS(), []*operation.Point{proof.t1, proof.t2})
+	xSquare := new(operation.Scalar).Mul(x, x)
+
+	// g^tHat * h^tauX = V^(z^2) * g^delta(y,z) * T1^x * T2^(x^2)
+	yVector := powerVector(y, N)
+	deltaYZ := computeDeltaYZ(z, zSquare, yVector, N, maxExp)
+	// invert y and all inner product challenges at once
+	challenges := innerProductChallenges(x.ToBytesS(), proof.innerProductProof.l, proof.innerProductProof.r)
+	inverses, err := batchInverse(append([]*operation.Scalar{y}, challenges...))
+	if err != nil {
+		return false, err
+	}
+	yInverse, challengeInverses := inverses[0], inverses[1:]
+	// HPrime = H^(y^(1-i), kept as the scalars y^(1-i) over the static table of H
+	yInverseVector := prepareHPrime(yInverse, N)
+
+	st1Builder := NewMSMultBuilder(true).SetWorkers(MSMWorkers)
+	// Verify eq (65)
+	// skip error for Append() calls since lengths are known to match
+	st1Builder.AppendSingle(xSquare, proof.t2)
+	st1Builder.Append([]*operation.Scalar{deltaYZ, x}, []*operation.Point{params.valueBase, proof.t1})
+	expVector := powerVector(z, numValuePad)
+	st1Builder.Append(expVector.MulScalar(expVector, zSquare).Ptrs(), cmsValue)
+	st1Builder.AppendWithMultiplier([]*operation.Scalar{proof.tHat, proof.tauX}, []*operation.Point{params.valueBase, params.blindingBase}, operation.NewScalar().Set(operation.ScMinusOne))
+
+	// Verify eq (66)
+	st2Builder := NewMSMultBuilder(true)
+	vectorSum := operation.NewScalarVector(N)
+	zTmp := new(operation.Scalar).Set(z)
+	for j := 0; j < numValuePad; j++ {
+		zTmp.Mul(zTmp, z)
+		vectorSum[j*maxExp:(j+1)*maxExp].MulScalar(twoVectorN, zTmp)
+	}
+	vectorSum.MulScalarAdd(yVector, z, vectorSum)
+	HPrime_vectorSum := vectorSum.Hadamard(yInverseVector, vectorSum)
+	if err := st2Builder.AppendTable(HPrime_vectorSum.Ptrs(), params.hTable, 0); err != nil {
+		return false, err
+	}
+	tmpG := new(operation.Point).Set(params.g[0])
+	for i := 1; i < N; i++ {
+		tmpG.Add(tmpG, params.g[i])
+	}
+	st2Builder.AppendSingle(zNeg, tmpG)
+	st2Builder.Append([]*operation.Scalar{operation.NewScalar().FromUint64(1), x}, []*operation.Point{proof.a, proof.s}) // AS^x
+	st2Builder.AppendSingle(operation.NewScalar().Mul(proof.tHat, operation.HashToScalar(x.ToBytesS())), params.u)       // tHat.U'
+	st2Builder.AppendWithMultiplier([]*operation.Scalar{operation.NewScalar().FromUint64(1), proof.mu}, []*operation.Point{proof.innerProductProof.p, params.blindingBase}, operation.NewScalar().Set(operation.ScMinusOne))
+
+	// Verify eq (68)
+	L := proof.innerProductProof.l
+	R := proof.innerProductProof.r
+	s := operation.NewScalarVector(N).Fill(proof.innerProductProof.a)
+	sInverse := operation.NewScalarVector(N).Fill(proof.innerProductProof.b)
+	logN := int(math.Log2(float64(N)))
+	vSquareList := make([]*operation.Scalar, logN)
+	vInverseSquareList := make([]*operation.Scalar, logN)
+
+	for i := range L {
+		v := challenges[i]
+		vInverse := challengeInverses[i]
+		vSquareList[i] = new(operation.Scalar).Mul(v, v)
+		vInverseSquareList[i] = new(operation.Scalar).Mul(vInverse, vInverse)
+
+		for j := 0; j < N; j++ {
+			if j&int(math.Pow(2, float64(logN-i-1))) != 0 {
+				s[j].Mul(&s[j], v)
+				sInverse[j].Mul(&sInverse[j], vInverse)
+			} else {
+				s[j].Mul(&s[j], vInverse)
+				sInverse[j].Mul(&sInverse[j], v)
+			}
+		}
+	}
+
+	st3Builder := NewMSMultBuilder(true)
+	c := new(operation.Scalar).Mul(proof.innerProductProof.a, proof.innerProductProof.b)
+	HPrime_sInverse := sInverse.Hadamard(yInverseVector, sInverse)
+	if _, err := encodeVectorsTable(s, HPrime_sInverse, params.gTable, params.hTable, st3Builder); err != nil {
+		return false, err
+	}
+	st3Builder.AppendSingle(operation.NewScalar().Mul(c, operation.HashToScalar(x.ToBytesS())), params.u) // cU'
+	rhsBuilder := NewMSMultBuilder(true)
+	rhsBuilder.Append(vSquareList, L)
+	rhsBuilder.Append(vInverseSquareList, R)
+	rhsBuilder.AppendSingle(operation.NewScalar().FromUint64(1), proof.innerProductProof.p)
+	st3Builder.AppendWithMultiplier(rhsBuilder.scalars, rhsBuilder.points, operation.NewScalar().Set(operation.ScMinusOne))
+
+	if err := st1Builder.AppendBuilderWithMultiplier(st2Builder, operation.RandomScalar()); err != nil {
+		return false, err
+	}
+	if err := st1Builder.AppendBuilderWithMultiplier(st3Builder, operation.RandomScalar()); err != nil {
+		return false, err
+	}
+	if !st1Builder.Execute().IsIdentity() {
+		Logger.Log.Errorf("verify aggregated range proof statement 2 failed")
+		return false, errors.New("verify aggregated range proof statement 2 failed")
+	}
+
+	return true, nil
+}
@@ -0,0 +1,147 @@
+package bulletproofs
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/dat-incognito-org/newbp/operation"
+	"github.com/pkg/errors"
+)
+
+// ErrProverPoolClosed is the error of jobs submitted to a ProverPool after Close
+var ErrProverPoolClosed = errors.New("prover pool is closed")
+
+// indices of the vectors in proverScratch
+const (
+	scratchAL = iota
+	scratchAR
+	scratchSL
+	scratchSR
+	scratchY
+	scratchSum
+	scratchL0
+	scratchR0
+	scratchR1
+	scratchHPrime
+	scratchVectors
+)
+
+// proverScratch holds the N-sized scalar vectors of a prover, so that consecutive proofs of one goroutine reuse them.
+// None of them ends up in a proof. A nil *proverScratch allocates fresh vectors.
+type proverScratch struct {
+	vectors [scratchVectors]operation.ScalarVector
+}
+
+// vector returns the i-th vector with length n. Its content is left over from the previous proof.
+func (scratch *proverScratch) vector(i, n int) operation.ScalarVector {
+	if scratch == nil {
+		return operation.NewScalarVector(n)
+	}
+	if cap(scratch.vectors[i]) < n {
+		scratch.vectors[i] = operation.NewScalarVector(n)
+	}
+	return scratch.vectors[i][:n]
+}
+
+// ProverResult is the outcome of one job of a ProverPool
+type ProverResult struct {
+	Proof *AggregatedRangeProof
+	Err   error
+}
+
+type proverJob struct {
+	ctx    context.Context
+	wit    *AggregatedRangeWitness
+	result chan ProverResult
+}
+
+// ProverPool proves independent witnesses on a bounded number of goroutines. The workers share the read-only
+// generators of their RangeProofParams and each keeps its own scratch vectors across jobs.
+// It is safe for concurrent use; Close stops the workers.
+type ProverPool struct {
+	params *RangeProofParams
+	jobs   chan proverJob
+	wg     sync.WaitGroup
+
+	// mu keeps Submit from sending on jobs once Close has closed it
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewProverPool starts a pool of the given number of workers over DefaultRangeProofParams.
+// A non-positive workers uses GOMAXPROCS.
+func NewProverPool(workers int) *ProverPool {
+	return NewProverPoolWithParams(DefaultRangeProofParams(), workers)
+}
+
+// NewProverPoolWithParams is NewProverPool over custom parameters
+func NewProverPoolWithParams(params *RangeProofParams, workers int) *ProverPool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	pool := &ProverPool{
+		params: params,
+		jobs:   make(chan proverJob),
+	}
+	pool.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+func (pool *ProverPool) work() {
+	defer pool.wg.Done()
+	scratch := new(proverScratch)
+	for job := range pool.jobs {
+		proof, err := pool.params.prove(job.ctx, job.wit, nil, legacyTranscript, scratch)
+		job.result <- ProverResult{Proof: proof, Err: err}
+	}
+}
+
+// Submit queues a proof of wit and returns the channel its result is delivered on. It blocks until a worker takes
+// the job or ctx is done; ctx also cancels the proof itself, like ProveContext. wit must not be modified until
+// the result is received.
+func (pool *ProverPool) Submit(ctx context.Context, wit *AggregatedRangeWitness) <-chan ProverResult {
+	result := make(chan ProverResult, 1)
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	if pool.closed {
+		result <- ProverResult{Err: ErrProverPoolClosed}
+		return result
+	}
+	select {
+	case pool.jobs <- proverJob{ctx: ctx, wit: wit, result: result}:
+	case <-ctx.Done():
+		result <- ProverResult{Err: ctx.Err()}
+	}
+	return result
+}
+
+// ProveAll proves every witness on the pool and returns their results in the order of wits
+func (pool *ProverPool) ProveAll(ctx context.Context, wits []*AggregatedRangeWitness) []ProverResult {
+	// Submit returns as soon as a worker takes the job, so the jobs spread over all workers
+	pending := make([]<-chan ProverResult, len(wits))
+	for i, wit := range wits {
+		pending[i] = pool.Submit(ctx, wit)
+	}
+	results := make([]ProverResult, len(wits))
+	for i := range pending {
+		results[i] = <-pending[i]
+	}
+	return results
+}
+
+// Close stops accepting jobs and waits for the running ones to finish
+func (pool *ProverPool) Close() {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		return
+	}
+	pool.closed = true
+	close(pool.jobs)
+	pool.mu.Unlock()
+	pool.wg.Wait()
+}
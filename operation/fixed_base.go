@@ -0,0 +1,208 @@
+package operation
+
+import (
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// fixedBaseWindows is the number of signed radix-16 digits of a scalar
+const fixedBaseWindows = 64
+
+// feD2 is 2*d, where d = -121665/121666 is the edwards25519 curve constant
+var feD2 = func() *field.Element {
+	d := new(field.Element).Invert(new(field.Element).Mult32(feOne, 121666))
+	d.Multiply(d, new(field.Element).Mult32(feOne, 121665))
+	d.Negate(d)
+	return d.Add(d, d)
+}()
+
+// affineNiels is an affine point in the (y+x, y-x, 2dxy) form used by mixed addition
+type affineNiels struct {
+	yPlusX, yMinusX, t2d field.Element
+}
+
+func (v *affineNiels) identity() *affineNiels {
+	v.yPlusX.One()
+	v.yMinusX.One()
+	v.t2d.Zero()
+	return v
+}
+
+// select sets v to a if cond == 1, and leaves it unchanged if cond == 0, in constant time
+func (v *affineNiels) selectIf(a *affineNiels, cond int) {
+	v.yPlusX.Select(&a.yPlusX, &v.yPlusX, cond)
+	v.yMinusX.Select(&a.yMinusX, &v.yMinusX, cond)
+	v.t2d.Select(&a.t2d, &v.t2d, cond)
+}
+
+// negateIf negates v if cond == 1, in constant time
+func (v *affineNiels) negateIf(cond int) {
+	v.yPlusX.Swap(&v.yMinusX, cond)
+	v.t2d.Select(new(field.Element).Negate(&v.t2d), &v.t2d, cond)
+}
+
+// extendedPoint is a point in extended coordinates, used as an accumulator outside of edwards25519.Point
+type extendedPoint struct {
+	X, Y, Z, T field.Element
+}
+
+func (v *extendedPoint) identity() *extendedPoint {
+	v.X.Zero()
+	v.Y.One()
+	v.Z.One()
+	v.T.Zero()
+	return v
+}
+
+// addAffine sets v = v + q (madd-2008-hwcd-3 for a = -1)
+func (v *extendedPoint) addAffine(q *affineNiels) {
+	var a, b, c, d, e, f, g, h field.Element
+	a.Subtract(&v.Y, &v.X)
+	a.Multiply(&a, &q.yMinusX)
+	b.Add(&v.Y, &v.X)
+	b.Multiply(&b, &q.yPlusX)
+	c.Multiply(&v.T, &q.t2d)
+	d.Add(&v.Z, &v.Z)
+	e.Subtract(&b, &a)
+	f.Subtract(&d, &c)
+	g.Add(&d, &c)
+	h.Add(&b, &a)
+	v.X.Multiply(&e, &f)
+	v.Y.Multiply(&g, &h)
+	v.T.Multiply(&e, &h)
+	v.Z.Multiply(&f, &g)
+}
+
+// FixedBaseTable holds precomputed multiples of a point that never changes.
+// Row j holds k*16^j*P for k = 1..8, so a scalar multiplication costs 64 mixed additions and no doublings.
+type FixedBaseTable struct {
+	base  Point
+	table [fixedBaseWindows][8]affineNiels
+}
+
+// NewFixedBaseTable precomputes the table for p. It costs about one field inversion plus 2048 point operations.
+func NewFixedBaseTable(p *Point) *FixedBaseTable {
+	t := &FixedBaseTable{}
+	t.base.Set(p)
+
+	// collect all multiples in extended coordinates, then normalize them with a single batched inversion
+	var multiples [fixedBaseWindows * 8]edwards25519.Point
+	rowBase := edwards25519.NewIdentityPoint().Set(&p.p)
+	for j := 0; j < fixedBaseWindows; j++ {
+		multiples[j*8].Set(rowBase)
+		for k := 1; k < 8; k++ {
+			multiples[j*8+k].Add(&multiples[j*8+k-1], rowBase)
+		}
+		// next row base is 16 * rowBase = 2 * (8 * rowBase)
+		rowBase.Add(&multiples[j*8+7], &multiples[j*8+7])
+	}
+
+	var zs [fixedBaseWindows * 8]field.Element
+	for i := range multiples {
+		_, _, Z, _ := multiples[i].ExtendedCoordinates()
+		zs[i].Set(Z)
+	}
+	batchInvertFieldElements(zs[:])
+
+	for i := range multiples {
+		X, Y, _, _ := multiples[i].ExtendedCoordinates()
+		var x, y field.Element
+		x.Multiply(X, &zs[i])
+		y.Multiply(Y, &zs[i])
+		entry := &t.table[i/8][i%8]
+		entry.yPlusX.Add(&y, &x)
+		entry.yMinusX.Subtract(&y, &x)
+		entry.t2d.Multiply(&x, &y)
+		entry.t2d.Multiply(&entry.t2d, feD2)
+	}
+	return t
+}
+
+// Base returns a copy of the point this table was built for
+func (t *FixedBaseTable) Base() *Point {
+	return new(Point).Set(&t.base)
+}
+
+// batchInvertFieldElements replaces every element of fes by its inverse, using Montgomery's trick.
+// All elements must be non-zero.
+func batchInvertFieldElements(fes []field.Element) {
+	if len(fes) == 0 {
+		return
+	}
+	prefix := make([]field.Element, len(fes))
+	acc := new(field.Element).One()
+	for i := range fes {
+		prefix[i].Set(acc)
+		acc.Multiply(acc, &fes[i])
+	}
+	acc.Invert(acc)
+	for i := len(fes) - 1; i >= 0; i-- {
+		tmp := new(field.Element).Multiply(acc, &prefix[i])
+		acc.Multiply(acc, &fes[i])
+		fes[i].Set(tmp)
+	}
+}
+
+// signedRadix16 returns the digits d_i in [-8, 8) with a = sum d_i * 16^i
+func signedRadix16(a *Scalar) [fixedBaseWindows]int8 {
+	b := a.s.Bytes()
+	var digits [fixedBaseWindows]int8
+	for i := 0; i < 32; i++ {
+		digits[2*i] = int8(b[i] & 15)
+		digits[2*i+1] = int8(b[i]>>4) & 15
+	}
+	for i := 0; i < fixedBaseWindows-1; i++ {
+		carry := (digits[i] + 8) >> 4
+		digits[i] -= carry << 4
+		digits[i+1] += carry
+	}
+	return digits
+}
+
+// accumulate adds a*base to acc in constant time
+func (t *FixedBaseTable) accumulate(acc *extendedPoint, a *Scalar) {
+	digits := signedRadix16(a)
+	var entry affineNiels
+	for j := 0; j < fixedBaseWindows; j++ {
+		d := digits[j]
+		// branchless |d| and sign
+		sign := int((uint8(d) >> 7) & 1)
+		abs := int8(int(d) - ((2 * int(d)) & -sign))
+		entry.identity()
+		for k := 1; k <= 8; k++ {
+			entry.selectIf(&t.table[j][k-1], eqInt8(abs, int8(k)))
+		}
+		entry.negateIf(sign)
+		acc.addAffine(&entry)
+	}
+}
+
+func eqInt8(a, b int8) int {
+	x := uint32(uint8(a ^ b))
+	return int((x - 1) >> 31)
+}
+
+func (p *Point) setExtended(acc *extendedPoint) *Point {
+	if _, err := p.p.SetExtendedCoordinates(&acc.X, &acc.Y, &acc.Z, &acc.T); err != nil {
+		// unreachable: the accumulator only ever holds curve points
+		panic(err)
+	}
+	return p
+}
+
+// ScalarMultTable does a * P, where P is the point t was built for. It runs in constant time.
+func (p *Point) ScalarMultTable(t *FixedBaseTable, a *Scalar) *Point {
+	var acc extendedPoint
+	acc.identity()
+	t.accumulate(&acc, a)
+	return p.setExtended(&acc)
+}
+
+// AddPedersenTable does aA + bB like AddPedersen, where A and B are the points tA and tB were built for.
+func (p *Point) AddPedersenTable(a *Scalar, tA *FixedBaseTable, b *Scalar, tB *FixedBaseTable) *Point {
+	var acc extendedPoint
+	acc.identity()
+	tA.accumulate(&acc, a)
+	tB.accumulate(&acc, b)
+	return p.setExtended(&acc)
+}
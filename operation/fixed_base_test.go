@@ -0,0 +1,26 @@
+package operation
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestFixedBaseTable(t *testing.T) {
+	table := NewFixedBaseTable(PedCom.G[PedersenValueIndex])
+	for _, sc := range []*Scalar{ScZero, ScOne, ScMinusOne, RandomScalar(), RandomScalar()} {
+		expected := new(Point).ScalarMult(PedCom.G[PedersenValueIndex], sc)
+		True(t, IsPointEqual(expected, new(Point).ScalarMultTable(table, sc)))
+	}
+
+	value, rand := RandomScalar(), RandomScalar()
+	expected := new(Point).AddPedersen(value, PedCom.G[PedersenValueIndex], rand, PedCom.G[PedersenRandomnessIndex])
+	True(t, IsPointEqual(expected, PedCom.CommitAtIndex(value, rand, PedersenValueIndex)))
+
+	// a copied scheme with a replaced base must not use the stale table
+	caScheme := PedCom
+	caScheme.G = append([]*Point{}, PedCom.G...)
+	caScheme.G[PedersenValueIndex] = RandomPoint()
+	expected = new(Point).AddPedersen(value, caScheme.G[PedersenValueIndex], rand, caScheme.G[PedersenRandomnessIndex])
+	True(t, IsPointEqual(expected, caScheme.CommitAtIndex(value, rand, PedersenValueIndex)))
+}
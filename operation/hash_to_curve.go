@@ -0,0 +1,296 @@
+package operation
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// hashToFieldLen is L = ceil((ceil(log2(p)) + k) / 8) for p = 2^255-19 and k = 128 (RFC 9380, section 5)
+const hashToFieldLen = 48
+
+var (
+	fieldOrder, _  = new(big.Int).SetString("7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed", 16)
+	scalarOrder, _ = new(big.Int).SetString("1000000000000000000000000000000014def9dea2f79cd65812631a5cf5d3ed", 16)
+
+	// RFC 9380 Elligator 2 constants for curve25519 / edwards25519 (appendix G.2)
+	feOne     = new(field.Element).One()
+	feJ       = new(field.Element).Mult32(feOne, 486662)
+	feSqrtM1  = mustFieldElement("b0a00e4a271beec478e42fad0618432fa7d7fb3d99004d2b0bdfc14f8024832b")
+	feC2      = fieldElementFromBig(new(big.Int).Exp(big.NewInt(2), new(big.Int).Rsh(new(big.Int).Add(fieldOrder, big.NewInt(3)), 3), fieldOrder))
+	feEdwards = sqrtNonNegative(new(field.Element).Negate(new(field.Element).Mult32(feOne, 486664)))
+
+	// constants of the legacy Monero-style map, see LegacyHashToPoint
+	feMa    = mustFieldElement("e792f8ffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f") // -A
+	feMa2   = mustFieldElement("c9e33ddbc8ffffffffffffffffffffffffffffffffffffffffffffffffffff7f") // -A^2
+	feFffb1 = mustFieldElement("ee411c327569a7228d732ab9a80494d1e319fb4137c5a920171bd6daeffb717e") // sqrt(-2 * A * (A + 2))
+	feFffb2 = mustFieldElement("e09a7c608364ded2dff756044603de51be5f16c0b751d491f62c5a040a1e064d") // sqrt(2 * A * (A + 2))
+	feFffb3 = mustFieldElement("662c3017877d1b58294296a54eff2440eda20d3f404695b8ef08c2140d114a67") // sqrt(-sqrt(-1) * A * (A + 2))
+	feFffb4 = mustFieldElement("8691b3b603193d85494a3fa108fc46ee2e43f77e88f4c026f9db671003f3431a") // sqrt(sqrt(-1) * A * (A + 2))
+)
+
+func mustFieldElement(s string) *field.Element {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	fe, err := new(field.Element).SetBytes(b)
+	if err != nil {
+		panic(err)
+	}
+	return fe
+}
+
+func fieldElementFromBig(n *big.Int) *field.Element {
+	var be [Ed25519KeySize]byte
+	n.FillBytes(be[:])
+	le := Reverse(be)
+	fe, err := new(field.Element).SetBytes(le[:])
+	if err != nil {
+		panic(err)
+	}
+	return fe
+}
+
+func sqrtNonNegative(a *field.Element) *field.Element {
+	r, wasSquare := new(field.Element).SqrtRatio(a, feOne)
+	if wasSquare != 1 {
+		panic("constant is not a square")
+	}
+	return r
+}
+
+// ExpandMessageXMD implements expand_message_xmd with SHA-512 (RFC 9380, section 5.3.1).
+// The DST must be between 1 and 255 bytes long.
+func ExpandMessageXMD(msg, dst []byte, lenInBytes int) ([]byte, error) {
+	const bInBytes, sInBytes = sha512.Size, sha512.BlockSize
+	ell := (lenInBytes + bInBytes - 1) / bInBytes
+	if lenInBytes <= 0 || ell > 255 || lenInBytes > 65535 {
+		return nil, fmt.Errorf("invalid expand_message_xmd output length %d", lenInBytes)
+	}
+	if len(dst) == 0 || len(dst) > 255 {
+		return nil, fmt.Errorf("invalid domain separation tag length %d", len(dst))
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	h := sha512.New()
+	h.Write(make([]byte, sInBytes))
+	h.Write(msg)
+	h.Write([]byte{byte(lenInBytes >> 8), byte(lenInBytes), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	h.Reset()
+	h.Write(b0)
+	h.Write([]byte{1})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+
+	uniform := make([]byte, 0, ell*bInBytes)
+	uniform = append(uniform, bi...)
+	for i := 2; i <= ell; i++ {
+		tmp := make([]byte, bInBytes)
+		for j := range tmp {
+			tmp[j] = b0[j] ^ bi[j]
+		}
+		h.Reset()
+		h.Write(tmp)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		uniform = append(uniform, bi...)
+	}
+	return uniform[:lenInBytes], nil
+}
+
+// reduceBigEndian interprets b as a big-endian integer and returns it mod m as 32 little-endian bytes
+func reduceBigEndian(b []byte, m *big.Int) []byte {
+	n := new(big.Int).SetBytes(b)
+	n.Mod(n, m)
+	return fieldElementFromBig(n).Bytes()
+}
+
+// HashToField implements hash_to_field for GF(2^255-19) with expand_message_xmd and SHA-512 (RFC 9380, section 5.2).
+func HashToField(msg, dst []byte, count int) ([]*field.Element, error) {
+	uniform, err := ExpandMessageXMD(msg, dst, count*hashToFieldLen)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*field.Element, count)
+	for i := range result {
+		chunk := uniform[i*hashToFieldLen : (i+1)*hashToFieldLen]
+		result[i], err = new(field.Element).SetBytes(reduceBigEndian(chunk, fieldOrder))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// HashToScalarWithDST hashes msg to a scalar mod l, following hash_to_field with expand_message_xmd and SHA-512.
+func HashToScalarWithDST(msg, dst []byte) (*Scalar, error) {
+	uniform, err := ExpandMessageXMD(msg, dst, hashToFieldLen)
+	if err != nil {
+		return nil, err
+	}
+	return NewScalar().FromBytesSStrict(reduceBigEndian(uniform, scalarOrder))
+}
+
+// mapToCurveElligator2 is map_to_curve_elligator2_edwards25519 (RFC 9380, appendix G.2.2).
+// The straight-line steps of map_to_curve_elligator2_curve25519 (appendix G.2.1) are inlined.
+func mapToCurveElligator2(u *field.Element) *edwards25519.Point {
+	// Montgomery curve25519 map
+	tv1 := new(field.Element).Square(u)
+	tv1.Add(tv1, tv1)
+	xd := new(field.Element).Add(tv1, feOne)
+	x1n := new(field.Element).Negate(feJ)
+	tv2 := new(field.Element).Square(xd)
+	gxd := new(field.Element).Multiply(tv2, xd)
+	gx1 := new(field.Element).Multiply(feJ, tv1)
+	gx1.Multiply(gx1, x1n)
+	gx1.Add(gx1, tv2)
+	gx1.Multiply(gx1, x1n)
+	tv3 := new(field.Element).Square(gxd)
+	tv2.Square(tv3)
+	tv3.Multiply(tv3, gxd)
+	tv3.Multiply(tv3, gx1)
+	tv2.Multiply(tv2, tv3)
+	y11 := new(field.Element).Pow22523(tv2)
+	y11.Multiply(y11, tv3)
+	y12 := new(field.Element).Multiply(y11, feSqrtM1)
+	tv2.Square(y11)
+	tv2.Multiply(tv2, gxd)
+	e1 := tv2.Equal(gx1)
+	y1 := new(field.Element).Select(y11, y12, e1)
+	x2n := new(field.Element).Multiply(x1n, tv1)
+	y21 := new(field.Element).Multiply(y11, u)
+	y21.Multiply(y21, feC2)
+	y22 := new(field.Element).Multiply(y21, feSqrtM1)
+	gx2 := new(field.Element).Multiply(gx1, tv1)
+	tv2.Square(y21)
+	tv2.Multiply(tv2, gxd)
+	e2 := tv2.Equal(gx2)
+	y2 := new(field.Element).Select(y21, y22, e2)
+	tv2.Square(y1)
+	tv2.Multiply(tv2, gxd)
+	e3 := tv2.Equal(gx1)
+	xMn := new(field.Element).Select(x1n, x2n, e3)
+	y := new(field.Element).Select(y1, y2, e3)
+	e4 := y.IsNegative()
+	y.Select(new(field.Element).Negate(y), y, e3^e4)
+	xMd, yMn := xd, y
+
+	// rational map to edwards25519, yMd = 1
+	xn := new(field.Element).Multiply(xMn, feEdwards)
+	xdE := new(field.Element).Multiply(xMd, yMn)
+	yn := new(field.Element).Subtract(xMn, xMd)
+	yd := new(field.Element).Add(xMn, xMd)
+	e := new(field.Element).Multiply(xdE, yd).Equal(new(field.Element).Zero())
+	xn.Select(new(field.Element).Zero(), xn, e)
+	xdE.Select(feOne, xdE, e)
+	yn.Select(feOne, yn, e)
+	yd.Select(feOne, yd, e)
+
+	X := new(field.Element).Multiply(xn, yd)
+	Y := new(field.Element).Multiply(yn, xdE)
+	Z := new(field.Element).Multiply(xdE, yd)
+	T := new(field.Element).Multiply(xn, yn)
+	p, err := edwards25519.NewIdentityPoint().SetExtendedCoordinates(X, Y, Z, T)
+	if err != nil {
+		// unreachable: the map always lands on the curve
+		panic(err)
+	}
+	return p
+}
+
+// HashToCurve implements the edwards25519_XMD:SHA-512_ELL2_RO_ suite of RFC 9380.
+// Callers must pass their own domain separation tag; see DSTHashToCurve.
+func HashToCurve(msg, dst []byte) (*Point, error) {
+	u, err := HashToField(msg, dst, 2)
+	if err != nil {
+		return nil, err
+	}
+	q := mapToCurveElligator2(u[0])
+	q.Add(q, mapToCurveElligator2(u[1]))
+	result := NewIdentityPoint()
+	result.p.MultByCofactor(q)
+	return result, nil
+}
+
+// LegacyHashToPoint is the Monero-style hash_to_ec map used by incognito-chain:
+// it hashes with Keccak256 twice, maps the digest with ge_fromfe_frombytes_vartime, then clears the cofactor.
+// It is kept so that PedCom and AggParam generators stay byte-identical.
+func LegacyHashToPoint(b []byte) *Point {
+	h := Keccak256(b)
+	h = Keccak256(h[:])
+
+	// the digest is read as a full 256-bit integer, so the top bit contributes 2^255 = 19 mod p
+	top := h[31] >> 7
+	h[31] &= 0x7f
+	u, _ := new(field.Element).SetBytes(h[:])
+	u.Add(u, new(field.Element).Mult32(feOne, 19*uint32(top)))
+
+	v := new(field.Element).Square(u)
+	v.Add(v, v)                           // 2 * u^2
+	w := new(field.Element).Add(v, feOne) // w = 2 * u^2 + 1
+	x := new(field.Element).Square(w)
+	x.Add(x, new(field.Element).Multiply(feMa2, v)) // x = w^2 - 2 * A^2 * u^2
+
+	// X = (w / x)^(m + 1) = w * x^3 * (w * x^7)^((p-5)/8)
+	x3 := new(field.Element).Square(x)
+	x3.Multiply(x3, x)
+	wx7 := new(field.Element).Square(x3)
+	wx7.Multiply(wx7, x)
+	wx7.Multiply(wx7, w)
+	X := new(field.Element).Pow22523(wx7)
+	X.Multiply(X, x3)
+	X.Multiply(X, w)
+
+	y := new(field.Element).Square(X)
+	x.Multiply(y, x)
+	z := new(field.Element).Set(feMa)
+	zero := new(field.Element).Zero()
+	var sign int
+	if new(field.Element).Subtract(w, x).Equal(zero) == 1 {
+		X.Multiply(X, feFffb2)
+	} else if new(field.Element).Add(w, x).Equal(zero) == 1 {
+		X.Multiply(X, feFffb1)
+	} else {
+		x.Multiply(x, feSqrtM1)
+		if new(field.Element).Subtract(w, x).Equal(zero) == 1 {
+			X.Multiply(X, feFffb4)
+		} else {
+			X.Multiply(X, feFffb3)
+		}
+		// X = sqrt(A * (A + 2) * w / x), z = -A
+		sign = 1
+	}
+	if sign == 0 {
+		X.Multiply(X, u) // u * sqrt(2 * A * (A + 2) * w / x)
+		z.Multiply(z, v) // -2 * A * u^2
+	}
+	if X.IsNegative() != sign {
+		X.Negate(X)
+	}
+	Z := new(field.Element).Add(z, w)
+	Y := new(field.Element).Subtract(z, w)
+	X.Multiply(X, Z)
+
+	// projective (X : Y : Z) to extended coordinates
+	p, err := edwards25519.NewIdentityPoint().SetExtendedCoordinates(
+		new(field.Element).Multiply(X, Z),
+		new(field.Element).Multiply(Y, Z),
+		new(field.Element).Square(Z),
+		new(field.Element).Multiply(X, Y),
+	)
+	if err != nil {
+		panic(err)
+	}
+	result := NewIdentityPoint()
+	result.p.MultByCofactor(p)
+	return result
+}
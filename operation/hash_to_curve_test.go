@@ -0,0 +1,29 @@
+package operation
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestHashToCurveRFC9380(t *testing.T) {
+	// test vectors from RFC 9380, appendices J.5.1 and K.3
+	uniform, err := ExpandMessageXMD([]byte(""), []byte("QUUX-V01-CS02-with-expander-SHA512-256"), 0x20)
+	Nil(t, err)
+	Equal(t, "6b9a7312411d92f921c6f68ca0b6380730a1a4d982c507211a90964c394179ba", fmt.Sprintf("%x", uniform))
+
+	dst := []byte("QUUX-V01-CS02-with-edwards25519_XMD:SHA-512_ELL2_RO_")
+	p, err := HashToCurve([]byte(""), dst)
+	Nil(t, err)
+	// compressed form: little-endian y with the parity of x in the top bit
+	x, _ := new(big.Int).SetString("3c3da6925a3c3c268448dcabb47ccde5439559d9599646a8260e47b1e4822fc6", 16)
+	y, _ := new(big.Int).SetString("09a6c8561a0b22bef63124c588ce4c62ea83a3c899763af26d795302e115dc21", 16)
+	var expected [32]byte
+	copy(expected[:], y.FillBytes(make([]byte, 32)))
+	expected = Reverse(expected)
+	expected[31] |= byte(x.Bit(0)) << 7
+	Equal(t, expected[:], p.ToBytesS())
+	True(t, p.PointValid())
+}
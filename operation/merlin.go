@@ -0,0 +1,205 @@
+package operation
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Merlin transcripts (https://merlin.cool), as used by the dalek bulletproofs crate.
+// They are built on the STROBE-128 operations AD, meta-AD and PRF over Keccak-f[1600].
+
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations holds the rho offsets, indexed by x + 5y
+var keccakRotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+func keccakF1600(a *[25]uint64) {
+	var b [25]uint64
+	var c, d [5]uint64
+	for round := 0; round < 24; round++ {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d[x] = c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+		}
+		for i := range a {
+			a[i] ^= d[i%5]
+		}
+		// rho and pi: B[y, 2x+3y] = rot(A[x, y])
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+		// chi
+		for y := 0; y < 5; y++ {
+			for x := 0; x < 5; x++ {
+				a[x+5*y] = b[x+5*y] ^ (^b[(x+1)%5+5*y] & b[(x+2)%5+5*y])
+			}
+		}
+		// iota
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
+
+const (
+	strobeR = 166
+
+	strobeFlagI = 1 << 0
+	strobeFlagA = 1 << 1
+	strobeFlagC = 1 << 2
+	strobeFlagT = 1 << 3
+	strobeFlagM = 1 << 4
+	strobeFlagK = 1 << 5
+)
+
+// strobe128 is the subset of STROBE-128 that Merlin needs, following merlin's strobe.rs
+type strobe128 struct {
+	state    [200]byte
+	pos      byte
+	posBegin byte
+	curFlags byte
+}
+
+func newStrobe128(protocolLabel []byte) *strobe128 {
+	s := new(strobe128)
+	copy(s.state[0:6], []byte{1, strobeR + 2, 1, 0, 1, 96})
+	copy(s.state[6:18], "STROBEv1.0.2")
+	s.permute()
+	s.metaAD(protocolLabel, false)
+	return s
+}
+
+func (s *strobe128) permute() {
+	var a [25]uint64
+	for i := range a {
+		a[i] = binary.LittleEndian.Uint64(s.state[8*i:])
+	}
+	keccakF1600(&a)
+	for i := range a {
+		binary.LittleEndian.PutUint64(s.state[8*i:], a[i])
+	}
+}
+
+func (s *strobe128) runF() {
+	s.state[s.pos] ^= s.posBegin
+	s.state[s.pos+1] ^= 0x04
+	s.state[strobeR+1] ^= 0x80
+	s.permute()
+	s.pos = 0
+	s.posBegin = 0
+}
+
+func (s *strobe128) absorb(data []byte) {
+	for _, b := range data {
+		s.state[s.pos] ^= b
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+}
+
+func (s *strobe128) squeeze(data []byte) {
+	for i := range data {
+		data[i] = s.state[s.pos]
+		s.state[s.pos] = 0
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+}
+
+func (s *strobe128) beginOp(flags byte, more bool) {
+	if more {
+		if s.curFlags != flags {
+			panic("strobe: continued operation with different flags")
+		}
+		return
+	}
+	if flags&strobeFlagT != 0 {
+		panic("strobe: transport operations are not supported")
+	}
+	oldBegin := s.posBegin
+	s.posBegin = s.pos + 1
+	s.curFlags = flags
+	s.absorb([]byte{oldBegin, flags})
+	// C and K force a permutation before the operation
+	if flags&(strobeFlagC|strobeFlagK) != 0 && s.pos != 0 {
+		s.runF()
+	}
+}
+
+func (s *strobe128) metaAD(data []byte, more bool) {
+	s.beginOp(strobeFlagM|strobeFlagA, more)
+	s.absorb(data)
+}
+
+func (s *strobe128) ad(data []byte, more bool) {
+	s.beginOp(strobeFlagA, more)
+	s.absorb(data)
+}
+
+func (s *strobe128) prf(data []byte, more bool) {
+	s.beginOp(strobeFlagI|strobeFlagA|strobeFlagC, more)
+	s.squeeze(data)
+}
+
+// MerlinTranscript is a Merlin v1.0 transcript. It is byte-compatible with merlin::Transcript from Rust.
+type MerlinTranscript struct {
+	strobe *strobe128
+}
+
+func NewMerlinTranscript(label []byte) *MerlinTranscript {
+	t := &MerlinTranscript{strobe: newStrobe128([]byte("Merlin v1.0"))}
+	t.AppendMessage([]byte("dom-sep"), label)
+	return t
+}
+
+func (t *MerlinTranscript) AppendMessage(label, message []byte) {
+	var dataLen [4]byte
+	binary.LittleEndian.PutUint32(dataLen[:], uint32(len(message)))
+	t.strobe.metaAD(label, false)
+	t.strobe.metaAD(dataLen[:], true)
+	t.strobe.ad(message, false)
+}
+
+// AppendUint64 appends x as 8 little-endian bytes
+func (t *MerlinTranscript) AppendUint64(label []byte, x uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], x)
+	t.AppendMessage(label, b[:])
+}
+
+// ChallengeBytes returns n bytes derived from everything appended so far
+func (t *MerlinTranscript) ChallengeBytes(label []byte, n int) []byte {
+	var dataLen [4]byte
+	binary.LittleEndian.PutUint32(dataLen[:], uint32(n))
+	t.strobe.metaAD(label, false)
+	t.strobe.metaAD(dataLen[:], true)
+	result := make([]byte, n)
+	t.strobe.prf(result, false)
+	return result
+}
+
+// ChallengeScalar reduces 64 challenge bytes to a scalar, like dalek's challenge_scalar
+func (t *MerlinTranscript) ChallengeScalar(label []byte) *Scalar {
+	sc, _ := NewScalar().FromBytesWide(t.ChallengeBytes(label, 2*Ed25519KeySize))
+	return sc
+}
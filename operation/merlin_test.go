@@ -0,0 +1,15 @@
+package operation
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestMerlinTranscript(t *testing.T) {
+	// test vector of the Rust merlin crate
+	transcript := NewMerlinTranscript([]byte("test protocol"))
+	transcript.AppendMessage([]byte("some label"), []byte("some data"))
+	Equal(t, "d5a21972d0d5fe320c0d263fac7fffb8145aa640af6e9bca177c03c7efcf0615", fmt.Sprintf("%x", transcript.ChallengeBytes([]byte("challenge"), 32)))
+}
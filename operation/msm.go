@@ -0,0 +1,300 @@
+package operation
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+const (
+	// staticNafWidth is the w-NAF width for points with cached tables; digits are odd and |d| < 2^(w-1)
+	staticNafWidth = 6
+	// dynamicNafWidth is the w-NAF width for points whose tables are built per call
+	dynamicNafWidth = 5
+)
+
+// MSMTable caches per-point lookup tables for a list of points that never change, such as generator vectors.
+// Multi-scalar multiplications over these points then skip the per-call table construction and use
+// cheaper mixed additions and a wider NAF window. Tables are built lazily on first use and are safe for concurrent use.
+type MSMTable struct {
+	points []Point
+
+	nafOnce   sync.Once
+	naf       [][1 << (staticNafWidth - 2)]affineNiels // P, 3P, ..., (2^(w-1)-1)P
+	radixOnce sync.Once
+	radix     [][8]affineNiels // P, 2P, ..., 8P
+}
+
+// NewMSMTable prepares a table for points. The points are copied.
+func NewMSMTable(points []*Point) *MSMTable {
+	t := &MSMTable{points: make([]Point, len(points))}
+	for i, p := range points {
+		t.points[i].Set(p)
+	}
+	return t
+}
+
+// Len returns the number of points in the table
+func (t *MSMTable) Len() int {
+	return len(t.points)
+}
+
+// Point returns a copy of the i-th point
+func (t *MSMTable) Point(i int) *Point {
+	return new(Point).Set(&t.points[i])
+}
+
+func (t *MSMTable) nafTables() [][1 << (staticNafWidth - 2)]affineNiels {
+	t.nafOnce.Do(func() {
+		t.naf = make([][1 << (staticNafWidth - 2)]affineNiels, len(t.points))
+		for i := range t.points {
+			oddMultiples(t.naf[i][:], &t.points[i].p)
+		}
+	})
+	return t.naf
+}
+
+func (t *MSMTable) radixTables() [][8]affineNiels {
+	t.radixOnce.Do(func() {
+		t.radix = make([][8]affineNiels, len(t.points))
+		for i := range t.points {
+			consecutiveMultiples(t.radix[i][:], &t.points[i].p)
+		}
+	})
+	return t.radix
+}
+
+// MSMTableTerms selects len(Scalars) consecutive points of Table starting at Offset
+type MSMTableTerms struct {
+	Table   *MSMTable
+	Offset  int
+	Scalars []*Scalar
+}
+
+// oddMultiples fills out with P, 3P, 5P, ...
+func oddMultiples(out []affineNiels, p *edwards25519.Point) {
+	multiples := make([]edwards25519.Point, len(out))
+	p2 := edwards25519.NewIdentityPoint().Add(p, p)
+	multiples[0].Set(p)
+	for i := 1; i < len(multiples); i++ {
+		multiples[i].Add(&multiples[i-1], p2)
+	}
+	toAffineNiels(out, multiples)
+}
+
+// consecutiveMultiples fills out with P, 2P, 3P, ...
+func consecutiveMultiples(out []affineNiels, p *edwards25519.Point) {
+	multiples := make([]edwards25519.Point, len(out))
+	multiples[0].Set(p)
+	for i := 1; i < len(multiples); i++ {
+		multiples[i].Add(&multiples[i-1], p)
+	}
+	toAffineNiels(out, multiples)
+}
+
+// toAffineNiels normalizes points with one batched inversion
+func toAffineNiels(out []affineNiels, points []edwards25519.Point) {
+	zs := make([]field.Element, len(points))
+	for i := range points {
+		_, _, Z, _ := points[i].ExtendedCoordinates()
+		zs[i].Set(Z)
+	}
+	batchInvertFieldElements(zs)
+	for i := range points {
+		X, Y, _, _ := points[i].ExtendedCoordinates()
+		var x, y field.Element
+		x.Multiply(X, &zs[i])
+		y.Multiply(Y, &zs[i])
+		out[i].yPlusX.Add(&y, &x)
+		out[i].yMinusX.Subtract(&y, &x)
+		out[i].t2d.Multiply(&x, &y)
+		out[i].t2d.Multiply(&out[i].t2d, feD2)
+	}
+}
+
+// subAffine sets v = v - q
+func (v *extendedPoint) subAffine(q *affineNiels) {
+	var a, b, c, d, e, f, g, h field.Element
+	a.Subtract(&v.Y, &v.X)
+	a.Multiply(&a, &q.yPlusX)
+	b.Add(&v.Y, &v.X)
+	b.Multiply(&b, &q.yMinusX)
+	c.Multiply(&v.T, &q.t2d)
+	d.Add(&v.Z, &v.Z)
+	e.Subtract(&b, &a)
+	f.Add(&d, &c)
+	g.Subtract(&d, &c)
+	h.Add(&b, &a)
+	v.X.Multiply(&e, &f)
+	v.Y.Multiply(&g, &h)
+	v.T.Multiply(&e, &h)
+	v.Z.Multiply(&f, &g)
+}
+
+// double sets v = 2v (dbl-2008-hwcd for a = -1)
+func (v *extendedPoint) double() {
+	var a, b, c, e, g, f, h field.Element
+	a.Square(&v.X)
+	b.Square(&v.Y)
+	c.Square(&v.Z)
+	c.Add(&c, &c)
+	e.Add(&v.X, &v.Y)
+	e.Square(&e)
+	e.Subtract(&e, &a)
+	e.Subtract(&e, &b)
+	g.Subtract(&b, &a)
+	f.Subtract(&g, &c)
+	h.Add(&a, &b)
+	h.Negate(&h)
+	v.X.Multiply(&e, &f)
+	v.Y.Multiply(&g, &h)
+	v.T.Multiply(&e, &h)
+	v.Z.Multiply(&f, &g)
+}
+
+// nonAdjacentForm returns the width-w NAF of a: digits are zero or odd with |d| < 2^(w-1)
+func nonAdjacentForm(a *Scalar, w uint) [256]int8 {
+	b := a.s.Bytes()
+	var words [5]uint64
+	for i := 0; i < 4; i++ {
+		words[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	width := uint64(1) << w
+	windowMask := width - 1
+
+	var naf [256]int8
+	var carry uint64
+	pos := uint(0)
+	for pos < 256 {
+		indexWord, indexBit := pos/64, pos%64
+		var bitBuf uint64
+		if indexBit < 64-w {
+			bitBuf = words[indexWord] >> indexBit
+		} else {
+			bitBuf = (words[indexWord] >> indexBit) | (words[indexWord+1] << (64 - indexBit))
+		}
+		window := carry + (bitBuf & windowMask)
+		if window&1 == 0 {
+			// an even window only needs the carry pushed one bit up
+			pos++
+			continue
+		}
+		if window < width/2 {
+			carry = 0
+			naf[pos] = int8(window)
+		} else {
+			carry = 1
+			naf[pos] = int8(int64(window) - int64(width))
+		}
+		pos += w
+	}
+	return naf
+}
+
+type msmTerm struct {
+	naf   [256]int8
+	table []affineNiels
+}
+
+// VarTimeMultiScalarMultTables computes the sum of the table terms plus sum scalars[i]*points[i], in variable time.
+// Only use it with public scalars.
+func (p *Point) VarTimeMultiScalarMultTables(tableTerms []MSMTableTerms, scalarLs []*Scalar, pointLs []*Point) *Point {
+	if len(scalarLs) != len(pointLs) {
+		panic("Cannot MultiscalarMul with different size inputs")
+	}
+	dynamicTables := make([][1 << (dynamicNafWidth - 2)]affineNiels, len(pointLs))
+	for i := range pointLs {
+		oddMultiples(dynamicTables[i][:], &pointLs[i].p)
+	}
+
+	var terms []msmTerm
+	for i := range scalarLs {
+		terms = append(terms, msmTerm{nonAdjacentForm(scalarLs[i], dynamicNafWidth), dynamicTables[i][:]})
+	}
+	for _, tt := range tableTerms {
+		if tt.Offset < 0 || tt.Offset+len(tt.Scalars) > tt.Table.Len() {
+			panic("MSM table terms out of range")
+		}
+		naf := tt.Table.nafTables()
+		for i, sc := range tt.Scalars {
+			terms = append(terms, msmTerm{nonAdjacentForm(sc, staticNafWidth), naf[tt.Offset+i][:]})
+		}
+	}
+
+	top := -1
+	for i := 255; i >= 0 && top < 0; i-- {
+		for k := range terms {
+			if terms[k].naf[i] != 0 {
+				top = i
+				break
+			}
+		}
+	}
+
+	var acc extendedPoint
+	acc.identity()
+	for i := top; i >= 0; i-- {
+		acc.double()
+		for k := range terms {
+			d := terms[k].naf[i]
+			if d > 0 {
+				acc.addAffine(&terms[k].table[d/2])
+			} else if d < 0 {
+				acc.subAffine(&terms[k].table[(-d)/2])
+			}
+		}
+	}
+	return p.setExtended(&acc)
+}
+
+// MultiScalarMultTables computes the sum of the table terms plus sum scalars[i]*points[i], in constant time.
+func (p *Point) MultiScalarMultTables(tableTerms []MSMTableTerms, scalarLs []*Scalar, pointLs []*Point) *Point {
+	if len(scalarLs) != len(pointLs) {
+		panic("Cannot MultiscalarMul with different size inputs")
+	}
+	type radixTerm struct {
+		digits [fixedBaseWindows]int8
+		table  *[8]affineNiels
+	}
+	dynamicTables := make([][8]affineNiels, len(pointLs))
+	var terms []radixTerm
+	for i := range pointLs {
+		consecutiveMultiples(dynamicTables[i][:], &pointLs[i].p)
+		terms = append(terms, radixTerm{signedRadix16(scalarLs[i]), &dynamicTables[i]})
+	}
+	for _, tt := range tableTerms {
+		if tt.Offset < 0 || tt.Offset+len(tt.Scalars) > tt.Table.Len() {
+			panic("MSM table terms out of range")
+		}
+		radix := tt.Table.radixTables()
+		for i, sc := range tt.Scalars {
+			terms = append(terms, radixTerm{signedRadix16(sc), &radix[tt.Offset+i]})
+		}
+	}
+
+	var acc extendedPoint
+	acc.identity()
+	var entry affineNiels
+	for j := fixedBaseWindows - 1; j >= 0; j-- {
+		if j < fixedBaseWindows-1 {
+			acc.double()
+			acc.double()
+			acc.double()
+			acc.double()
+		}
+		for k := range terms {
+			d := terms[k].digits[j]
+			sign := int((uint8(d) >> 7) & 1)
+			abs := int8(int(d) - ((2 * int(d)) & -sign))
+			entry.identity()
+			for e := 1; e <= 8; e++ {
+				entry.selectIf(&terms[k].table[e-1], eqInt8(abs, int8(e)))
+			}
+			entry.negateIf(sign)
+			acc.addAffine(&entry)
+		}
+	}
+	return p.setExtended(&acc)
+}
@@ -0,0 +1,131 @@
+package operation
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minParallelMSMChunk is the smallest number of terms worth handing to a separate goroutine
+const minParallelMSMChunk = 64
+
+type msmChunk struct {
+	tableTerms []MSMTableTerms
+	scalars    []*Scalar
+	points     []*Point
+}
+
+// splitMSM cuts the terms into at most workers chunks of about the same size, never smaller than minParallelMSMChunk
+func splitMSM(tableTerms []MSMTableTerms, scalarLs []*Scalar, pointLs []*Point, workers int) []msmChunk {
+	total := len(scalarLs)
+	for _, tt := range tableTerms {
+		total += len(tt.Scalars)
+	}
+	chunkSize := (total + workers - 1) / workers
+	if chunkSize < minParallelMSMChunk {
+		chunkSize = minParallelMSMChunk
+	}
+
+	var chunks []msmChunk
+	current := msmChunk{}
+	room := chunkSize
+	flush := func() {
+		chunks = append(chunks, current)
+		current = msmChunk{}
+		room = chunkSize
+	}
+	for start := 0; start < len(scalarLs); {
+		end := start + room
+		if end > len(scalarLs) {
+			end = len(scalarLs)
+		}
+		current.scalars = scalarLs[start:end]
+		current.points = pointLs[start:end]
+		room -= end - start
+		start = end
+		if room == 0 {
+			flush()
+		}
+	}
+	for _, tt := range tableTerms {
+		for start := 0; start < len(tt.Scalars); {
+			end := start + room
+			if end > len(tt.Scalars) {
+				end = len(tt.Scalars)
+			}
+			current.tableTerms = append(current.tableTerms, MSMTableTerms{Table: tt.Table, Offset: tt.Offset + start, Scalars: tt.Scalars[start:end]})
+			room -= end - start
+			start = end
+			if room == 0 {
+				flush()
+			}
+		}
+	}
+	if room < chunkSize {
+		flush()
+	}
+	return chunks
+}
+
+func runMSMChunk(p *Point, c msmChunk, varTime bool) *Point {
+	switch {
+	case len(c.tableTerms) > 0 && varTime:
+		return p.VarTimeMultiScalarMultTables(c.tableTerms, c.scalars, c.points)
+	case len(c.tableTerms) > 0:
+		return p.MultiScalarMultTables(c.tableTerms, c.scalars, c.points)
+	case varTime:
+		return p.VarTimeMultiScalarMult(c.scalars, c.points)
+	default:
+		return p.MultiScalarMult(c.scalars, c.points)
+	}
+}
+
+func parallelMSM(p *Point, tableTerms []MSMTableTerms, scalarLs []*Scalar, pointLs []*Point, workers int, varTime bool) *Point {
+	if len(scalarLs) != len(pointLs) {
+		panic("Cannot MultiscalarMul with different size inputs")
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	chunks := splitMSM(tableTerms, scalarLs, pointLs, workers)
+	if len(chunks) <= 1 {
+		return runMSMChunk(p, msmChunk{tableTerms, scalarLs, pointLs}, varTime)
+	}
+
+	partials := make([]Point, len(chunks))
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runMSMChunk(&partials[i], chunks[i], varTime)
+		}(i)
+	}
+	wg.Wait()
+
+	p.Set(&partials[0])
+	for i := 1; i < len(partials); i++ {
+		p.Add(p, &partials[i])
+	}
+	return p
+}
+
+// ParallelMultiScalarMult is MultiScalarMult split across up to workers goroutines.
+// A non-positive workers uses GOMAXPROCS. Inputs too small to be worth splitting run on the calling goroutine.
+func (p *Point) ParallelMultiScalarMult(scalarLs []*Scalar, pointLs []*Point, workers int) *Point {
+	return parallelMSM(p, nil, scalarLs, pointLs, workers, false)
+}
+
+// ParallelVarTimeMultiScalarMult is VarTimeMultiScalarMult split across up to workers goroutines. Only use it with public scalars.
+func (p *Point) ParallelVarTimeMultiScalarMult(scalarLs []*Scalar, pointLs []*Point, workers int) *Point {
+	return parallelMSM(p, nil, scalarLs, pointLs, workers, true)
+}
+
+// ParallelMultiScalarMultTables is MultiScalarMultTables split across up to workers goroutines
+func (p *Point) ParallelMultiScalarMultTables(tableTerms []MSMTableTerms, scalarLs []*Scalar, pointLs []*Point, workers int) *Point {
+	return parallelMSM(p, tableTerms, scalarLs, pointLs, workers, false)
+}
+
+// ParallelVarTimeMultiScalarMultTables is VarTimeMultiScalarMultTables split across up to workers goroutines. Only use it with public scalars.
+func (p *Point) ParallelVarTimeMultiScalarMultTables(tableTerms []MSMTableTerms, scalarLs []*Scalar, pointLs []*Point, workers int) *Point {
+	return parallelMSM(p, tableTerms, scalarLs, pointLs, workers, true)
+}
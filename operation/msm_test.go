@@ -0,0 +1,52 @@
+package operation
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestMSMTable(t *testing.T) {
+	n := 16
+	points := make([]*Point, n)
+	for i := range points {
+		points[i] = RandomPoint()
+	}
+	table := NewMSMTable(points)
+
+	offset := 3
+	tableScalars := []*Scalar{ScZero, ScOne, ScMinusOne}
+	for len(tableScalars) < n-offset {
+		tableScalars = append(tableScalars, RandomScalar())
+	}
+	dynScalars := []*Scalar{RandomScalar(), ScMinusOne}
+	dynPoints := []*Point{RandomPoint(), RandomPoint()}
+
+	expected := new(Point).MultiScalarMult(append(append([]*Scalar{}, tableScalars...), dynScalars...), append(append([]*Point{}, points[offset:]...), dynPoints...))
+	terms := []MSMTableTerms{{Table: table, Offset: offset, Scalars: tableScalars}}
+	True(t, IsPointEqual(expected, new(Point).VarTimeMultiScalarMultTables(terms, dynScalars, dynPoints)))
+	True(t, IsPointEqual(expected, new(Point).MultiScalarMultTables(terms, dynScalars, dynPoints)))
+	True(t, new(Point).VarTimeMultiScalarMultTables(nil, nil, nil).IsIdentity())
+}
+
+func TestParallelMSM(t *testing.T) {
+	n := 300
+	points := make([]*Point, n)
+	scalars := make([]*Scalar, n)
+	for i := range points {
+		points[i] = RandomPoint()
+		scalars[i] = RandomScalar()
+	}
+	table := NewMSMTable(points)
+	terms := []MSMTableTerms{{Table: table, Offset: 10, Scalars: scalars[10:200]}}
+	expected := new(Point).VarTimeMultiScalarMultTables(terms, scalars, points)
+
+	for _, workers := range []int{0, 1, 2, 3, 7, 64} {
+		True(t, IsPointEqual(expected, new(Point).ParallelVarTimeMultiScalarMultTables(terms, scalars, points, workers)))
+		True(t, IsPointEqual(expected, new(Point).ParallelMultiScalarMultTables(terms, scalars, points, workers)))
+	}
+	expected = new(Point).MultiScalarMult(scalars, points)
+	True(t, IsPointEqual(expected, new(Point).ParallelMultiScalarMult(scalars, points, 4)))
+	True(t, IsPointEqual(expected, new(Point).ParallelVarTimeMultiScalarMult(scalars, points, 4)))
+	True(t, new(Point).ParallelVarTimeMultiScalarMult(nil, nil, 4).IsIdentity())
+}
@@ -7,3 +7,19 @@ const (
 	CStringAssetTag    = "blindedassettag"
 	CStringOTA         = "onetimeaddress"
 )
+
+// domain separation tags for RFC 9380 hashing
+const (
+	DSTHashToCurve  = "NEWBP-V01-CS01-with-edwards25519_XMD:SHA-512_ELL2_RO_"
+	DSTHashToScalar = "NEWBP-V01-CS01-with-edwards25519_XMD:SHA-512_SCALAR_RO_"
+	DSTGeneratorV1  = "NEWBP-V01-GEN-with-edwards25519_XMD:SHA-512_ELL2_RO_"
+)
+
+// generator labels for GeneratorDerivationV1
+const (
+	CStringGeneratorV1 = "newbp-generator-v1"
+	CStringPedersen    = "pedersen"
+	CStringBPG         = "bulletproof-g"
+	CStringBPH         = "bulletproof-h"
+	CStringBPU         = "bulletproof-u"
+)
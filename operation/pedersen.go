@@ -17,22 +17,69 @@ var PedCom PedersenCommitment = NewPedersenParams()
 // PedersenCommitment represents the parameters for the commitment
 type PedersenCommitment struct {
 	G []*Point // generators
+	// tables are precomputed for G; an entry is only used while it still matches G at that index
+	tables []*FixedBaseTable
 }
 
 var GBase, HBase, RandomBase *Point
 
+// GBaseTable and HBaseTable are precomputed fixed-base tables for GBase and HBase
+var GBaseTable, HBaseTable *FixedBaseTable
+
 func NewPedersenParams() PedersenCommitment {
+	pcm, err := NewPedersenParamsWithDerivation(GeneratorDerivationLegacy)
+	if err != nil {
+		panic(err)
+	}
+	pcm.Precompute()
+	GBase = NewIdentityPoint().Set(pcm.G[1])
+	HBase = NewIdentityPoint().Set(pcm.G[4])
+	GBaseTable = pcm.tables[1]
+	HBaseTable = pcm.tables[4]
+	return pcm
+}
+
+// Precompute builds fixed-base tables for the current generators, which speeds up CommitAtIndex.
+// Replacing a generator afterwards is safe; the stale table is simply ignored.
+func (com *PedersenCommitment) Precompute() {
+	com.tables = make([]*FixedBaseTable, len(com.G))
+	for i, g := range com.G {
+		com.tables[i] = NewFixedBaseTable(g)
+	}
+}
+
+// tableAt returns the fixed-base table for G[index], or nil if there is none or it is stale
+func (com PedersenCommitment) tableAt(index byte) *FixedBaseTable {
+	if int(index) >= len(com.tables) || com.tables[index] == nil {
+		return nil
+	}
+	t := com.tables[index]
+	if !IsPointEqual(&t.base, com.G[index]) {
+		return nil
+	}
+	return t
+}
+
+// NewPedersenParamsWithDerivation builds the Pedersen generators under the given derivation scheme.
+// G[0] is always the curve basepoint. Unlike NewPedersenParams, it does not touch GBase and HBase.
+func NewPedersenParamsWithDerivation(version GeneratorDerivation) (PedersenCommitment, error) {
 	var pcm PedersenCommitment
 	const capacity = 5 // fixed value = 5
 	pcm.G = make([]*Point, capacity)
 	pcm.G[0] = NewIdentityPoint().ScalarMultBase(new(Scalar).FromUint64(1))
 
+	label := CStringBulletProof
+	if version != GeneratorDerivationLegacy {
+		label = CStringPedersen
+	}
 	for i := 1; i < len(pcm.G); i++ {
-		pcm.G[i] = HashToPointFromIndex(int64(i), CStringBulletProof)
+		var err error
+		pcm.G[i], err = DeriveGenerator(version, label, uint64(i))
+		if err != nil {
+			return PedersenCommitment{}, err
+		}
 	}
-	GBase = NewIdentityPoint().Set(pcm.G[1])
-	HBase = NewIdentityPoint().Set(pcm.G[4])
-	return pcm
+	return pcm, nil
 }
 
 // CommitAll commits a list of PCM_CAPACITY value(s)
@@ -52,5 +99,9 @@ func (com PedersenCommitment) CommitAll(openings []*Scalar) (*Point, error) {
 // CommitAtIndex commits specific value with index and returns 34 bytes
 // g^v x h^rand
 func (com PedersenCommitment) CommitAtIndex(value, rand *Scalar, index byte) *Point {
+	tValue, tRand := com.tableAt(index), com.tableAt(PedersenRandomnessIndex)
+	if tValue != nil && tRand != nil {
+		return NewIdentityPoint().AddPedersenTable(value, tValue, rand, tRand)
+	}
 	return NewIdentityPoint().AddPedersen(value, com.G[index], rand, com.G[PedersenRandomnessIndex])
 }
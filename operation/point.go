@@ -1,11 +1,15 @@
 package operation
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
+	"unicode/utf8"
 
 	"filippo.io/edwards25519"
-	operation_old "github.com/incognitochain/incognito-chain/privacy/operation"
 )
 
 type Point struct {
@@ -25,16 +29,19 @@ func NewIdentityPoint() *Point {
 	return &Point{ *edwards25519.NewIdentityPoint() }
 }
 
-// TODO
+// PointValid reports whether p lies in the prime-order subgroup, i.e. [l]p is the identity.
+// Points with a small-order (torsion) component fail this check. The identity itself passes.
 func (p Point) PointValid() bool {
-	return true
-	// var point C25519.ExtendedGroupElement
-	// isValid := point.FromBytes(&p.key)
-	// if !isValid {
-	// 	return false
-	// }
-	// lP := new(Point).ScalarMult(&p, curveOrder)
-	// return lP.IsIdentity()
+	// [l]p = [l-1]p + p, where l-1 is ScMinusOne as a canonical scalar
+	lP := edwards25519.NewIdentityPoint().VarTimeDoubleScalarBaseMult(&ScMinusOne.s, &p.p, &ScZero.s)
+	lP.Add(lP, &p.p)
+	return lP.Equal(edwards25519.NewIdentityPoint()) == 1
+}
+
+// PointValidNonIdentity is like PointValid, but also rejects the identity.
+// Use it for proof elements that the protocol forbids from being the identity.
+func (p Point) PointValidNonIdentity() bool {
+	return !p.IsIdentity() && p.PointValid()
 }
 
 func (p *Point) Set(q *Point) *Point {
@@ -48,24 +55,46 @@ func (p Point) String() string {
 	return fmt.Sprintf("%x", p.ToBytesS())
 }
 
-func (p Point) MarshalText() []byte {
-	return []byte(hex.EncodeToString(p.ToBytesS()))
+// MarshalText encodes p as the hex string of its 32-byte encoding
+func (p Point) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(p.ToBytesS())), nil
 }
 
 func (p Point) Show() string {
 	return hex.EncodeToString(p.ToBytesS())[:8]
 }
 
-func (p *Point) UnmarshalText(data []byte) (*Point, error) {
-	byteSlice, _ := hex.DecodeString(string(data))
-	if len(byteSlice) != Ed25519KeySize {
-		return nil, fmt.Errorf("invalid point byte size")
-	}
-	_, err := p.p.SetBytes(byteSlice)
+// UnmarshalText decodes the output of MarshalText. Like FromBytesSStrict, it rejects points outside the prime-order subgroup.
+func (p *Point) UnmarshalText(data []byte) error {
+	byteSlice, err := hex.DecodeString(string(data))
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("invalid point hex: %v", err)
 	}
-	return p, nil
+	return p.UnmarshalBinary(byteSlice)
+}
+
+// MarshalBinary returns the 32-byte encoding of p
+func (p Point) MarshalBinary() ([]byte, error) {
+	return p.ToBytesS(), nil
+}
+
+// UnmarshalBinary decodes a 32-byte encoding with FromBytesSStrict
+func (p *Point) UnmarshalBinary(data []byte) error {
+	_, err := p.FromBytesSStrict(data)
+	return err
+}
+
+// MarshalJSON encodes p as a JSON string holding MarshalText's hex
+func (p Point) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(p.ToBytesS()))
+}
+
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	return p.UnmarshalText([]byte(str))
 }
 
 func (p Point) ToBytesS() []byte {
@@ -83,6 +112,21 @@ func (p *Point) FromBytesS(b []byte) (*Point, error) {
 	return p, nil
 }
 
+// FromBytesSStrict decodes like FromBytesS, but additionally rejects non-canonical encodings
+// and points outside the prime-order subgroup. Use it on untrusted input.
+func (p *Point) FromBytesSStrict(b []byte) (*Point, error) {
+	if _, err := p.FromBytesS(b); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p.ToBytesS(), b) {
+		return nil, fmt.Errorf("non-canonical point encoding")
+	}
+	if !p.PointValid() {
+		return nil, fmt.Errorf("point is not in the prime-order subgroup")
+	}
+	return p, nil
+}
+
 // func (p *Point) FromBytesS(b []byte) (*Point, error) {
 // 	if len(b) != Ed25519KeySize {
 // 		return nil, fmt.Errorf("Invalid Ed25519 Key Size")
@@ -192,6 +236,13 @@ func (p *Point) Add(pa, pb *Point) *Point {
 	return p
 }
 
+func (p *Point) Sub(pa, pb *Point) *Point {
+	temp := edwards25519.NewIdentityPoint()
+	temp.Subtract(&pa.p, &pb.p)
+	p.p = *temp
+	return p
+}
+
 // aA + bB
 func (p *Point) AddPedersen(a *Scalar, A *Point, b *Scalar, B *Point) *Point {
 	result := NewIdentityPoint().MultiScalarMult([]*Scalar{a, b}, []*Point{A, B})
@@ -203,18 +254,66 @@ func IsPointEqual(pa *Point, pb *Point) bool {
 	return pa.p.Equal(&pb.p) == 1
 }
 
+// HashToPointFromIndex is the legacy generator derivation.
+// The index is encoded as a single UTF-8 rune, so indices that are not valid runes all collapse to U+FFFD.
+// Use DeriveGenerator with GeneratorDerivationV1 for new generator sets.
 func HashToPointFromIndex(index int64, padStr string) *Point {
 	msg := edwards25519.NewGeneratorPoint().Bytes()
 	msg = append(msg, []byte(padStr)...)
-	msg = append(msg, []byte(string(index))...)
+	msg = append(msg, legacyIndexBytes(index)...)
+
+	return LegacyHashToPoint(msg)
+}
 
-	return HashToPoint(msg)
+// legacyIndexBytes reproduces []byte(string(index)) for an int64 index
+func legacyIndexBytes(index int64) []byte {
+	if index < 0 || index > utf8.MaxRune || !utf8.ValidRune(rune(index)) {
+		return []byte(string(utf8.RuneError))
+	}
+	return []byte(string(rune(index)))
 }
 
-// legacy map-to-point
+// GeneratorDerivation selects the scheme used to derive a generator from a label and an index.
+type GeneratorDerivation byte
+
+const (
+	// GeneratorDerivationLegacy is HashToPointFromIndex: Keccak256 and the Monero-style map over a UTF-8 rune index.
+	GeneratorDerivationLegacy GeneratorDerivation = 0
+	// GeneratorDerivationV1 is HashToCurve with DSTGeneratorV1 over
+	// CStringGeneratorV1 || uint16(len(label)) || label || uint64(index), all integers big-endian.
+	GeneratorDerivationV1 GeneratorDerivation = 1
+)
+
+// DeriveGenerator returns the generator at index for label under the given derivation scheme.
+// Legacy indices are limited to the int64 range.
+func DeriveGenerator(version GeneratorDerivation, label string, index uint64) (*Point, error) {
+	switch version {
+	case GeneratorDerivationLegacy:
+		if index > math.MaxInt64 {
+			return nil, fmt.Errorf("legacy generator index %d out of range", index)
+		}
+		return HashToPointFromIndex(int64(index), label), nil
+	case GeneratorDerivationV1:
+		if len(label) > math.MaxUint16 {
+			return nil, fmt.Errorf("generator label too long")
+		}
+		var lenBytes [2]byte
+		var indexBytes [8]byte
+		binary.BigEndian.PutUint16(lenBytes[:], uint16(len(label)))
+		binary.BigEndian.PutUint64(indexBytes[:], index)
+		msg := []byte(CStringGeneratorV1)
+		msg = append(msg, lenBytes[:]...)
+		msg = append(msg, []byte(label)...)
+		msg = append(msg, indexBytes[:]...)
+		return HashToCurve(msg, []byte(DSTGeneratorV1))
+	default:
+		return nil, fmt.Errorf("unknown generator derivation %d", version)
+	}
+}
+
+// HashToPoint is the legacy map-to-point.
+//
+// Deprecated: use LegacyHashToPoint where the incognito-chain generators must be reproduced, or HashToCurve otherwise.
 func HashToPoint(b []byte) *Point {
-	temp := operation_old.HashToPoint(b)
-	result := &Point{}
-	result.FromBytesS(temp.ToBytesS())
-	return result
+	return LegacyHashToPoint(b)
 }
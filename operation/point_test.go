@@ -0,0 +1,41 @@
+package operation
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestPointValidRejectsTorsion(t *testing.T) {
+	// (0, -1) is the point of order 2
+	var order2 [32]byte
+	order2[0] = 0xec
+	for i := 1; i < 31; i++ {
+		order2[i] = 0xff
+	}
+	order2[31] = 0x7f
+	torsion, err := new(Point).FromBytesS(order2[:])
+	Nil(t, err)
+	False(t, torsion.PointValid())
+	_, err = new(Point).FromBytesSStrict(order2[:])
+	NotNil(t, err)
+
+	p := RandomPoint()
+	True(t, p.PointValid())
+	False(t, new(Point).Add(p, torsion).PointValid())
+	_, err = new(Point).FromBytesSStrict(p.ToBytesS())
+	Nil(t, err)
+}
+
+func TestGeneratorDerivation(t *testing.T) {
+	// legacy indices that are not valid runes all collapse to U+FFFD
+	Equal(t, HashToPointFromIndex(0xD800, CStringBulletProof).ToBytesS(), HashToPointFromIndex(0x110000, CStringBulletProof).ToBytesS())
+
+	g, err := DeriveGenerator(GeneratorDerivationV1, CStringBPG, 0)
+	Nil(t, err)
+	True(t, g.PointValidNonIdentity())
+	other, err := DeriveGenerator(GeneratorDerivationV1, CStringBPG, 0xD800)
+	Nil(t, err)
+	True(t, other.PointValidNonIdentity())
+	NotEqual(t, g.ToBytesS(), other.ToBytesS())
+}
@@ -0,0 +1,166 @@
+package operation
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// ristretto255 encoding, decoding and one-way map (RFC 9496, section 4.3), so that points produced by
+// curve25519-dalek's RistrettoPoint can be used with the rest of this package. A Point decoded from ristretto
+// bytes is one representative of its ristretto class; compare such points with their encodings, not IsPointEqual.
+
+func mustFieldElementDecimal(s string) *field.Element {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid field element constant")
+	}
+	return fieldElementFromBig(n)
+}
+
+var (
+	feD               = mustFieldElementDecimal("37095705934669439343138083508754565189542113879843219016388785533085940283555")
+	feSqrtADMinusOne  = mustFieldElementDecimal("25063068953384623474111414158702152701244531502492656460079210482610430750235")
+	feInvSqrtAMinusD  = mustFieldElementDecimal("54469307008909316920995813868745141605393597292927456921205312896311721017578")
+	feOneMinusDSquare = mustFieldElementDecimal("1159843021668779879193775521855586647937357759715417654439879720876111806838")
+	feDMinusOneSquare = mustFieldElementDecimal("40440834346308536858101042469323190826248399146238708352240133220865137265952")
+)
+
+// SetRistrettoBytes decodes a canonical 32-byte ristretto255 encoding into p
+func (p *Point) SetRistrettoBytes(b []byte) (*Point, error) {
+	if len(b) != Ed25519KeySize {
+		return nil, fmt.Errorf("invalid ristretto255 encoding length %d", len(b))
+	}
+	s, err := new(field.Element).SetBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	// reject non-canonical and negative field elements
+	if !bytes.Equal(s.Bytes(), b) || s.IsNegative() == 1 {
+		return nil, fmt.Errorf("invalid ristretto255 encoding")
+	}
+
+	ss := new(field.Element).Square(s)
+	u1 := new(field.Element).Subtract(feOne, ss)
+	u2 := new(field.Element).Add(feOne, ss)
+	u2Square := new(field.Element).Square(u2)
+	// v = -(D * u1^2) - u2^2
+	v := new(field.Element).Square(u1)
+	v.Multiply(v, feD)
+	v.Negate(v)
+	v.Subtract(v, u2Square)
+
+	invSqrt, wasSquare := new(field.Element).SqrtRatio(feOne, new(field.Element).Multiply(v, u2Square))
+	denX := new(field.Element).Multiply(invSqrt, u2)
+	denY := new(field.Element).Multiply(invSqrt, denX)
+	denY.Multiply(denY, v)
+
+	x := new(field.Element).Multiply(s, denX)
+	x.Add(x, x)
+	x.Absolute(x)
+	y := new(field.Element).Multiply(u1, denY)
+	t := new(field.Element).Multiply(x, y)
+	if wasSquare == 0 || t.IsNegative() == 1 || y.Equal(new(field.Element).Zero()) == 1 {
+		return nil, fmt.Errorf("invalid ristretto255 encoding")
+	}
+	if _, err := p.p.SetExtendedCoordinates(x, y, new(field.Element).One(), t); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RistrettoBytes returns the ristretto255 encoding of p. It is only meaningful for points of the form
+// 2Q + T with T of order 4 or less, which includes every point decoded with SetRistrettoBytes and every
+// combination of them.
+func (p Point) RistrettoBytes() []byte {
+	x0, y0, z0, t0 := p.p.ExtendedCoordinates()
+
+	u1 := new(field.Element).Add(z0, y0)
+	u1.Multiply(u1, new(field.Element).Subtract(z0, y0))
+	u2 := new(field.Element).Multiply(x0, y0)
+	invSqrt, _ := new(field.Element).SqrtRatio(feOne, new(field.Element).Multiply(u1, new(field.Element).Square(u2)))
+	den1 := new(field.Element).Multiply(invSqrt, u1)
+	den2 := new(field.Element).Multiply(invSqrt, u2)
+	zInv := new(field.Element).Multiply(den1, den2)
+	zInv.Multiply(zInv, t0)
+
+	ix0 := new(field.Element).Multiply(x0, feSqrtM1)
+	iy0 := new(field.Element).Multiply(y0, feSqrtM1)
+	enchantedDenominator := new(field.Element).Multiply(den1, feInvSqrtAMinusD)
+	rotate := new(field.Element).Multiply(t0, zInv).IsNegative()
+
+	x := new(field.Element).Select(iy0, x0, rotate)
+	y := new(field.Element).Select(ix0, y0, rotate)
+	denInv := new(field.Element).Select(enchantedDenominator, den2, rotate)
+	y.Select(new(field.Element).Negate(y), y, new(field.Element).Multiply(x, zInv).IsNegative())
+
+	s := new(field.Element).Subtract(z0, y)
+	s.Multiply(s, denInv)
+	s.Absolute(s)
+	return s.Bytes()
+}
+
+// ristrettoMap is the ristretto255 element derivation function MAP (RFC 9496, section 4.3.4)
+func ristrettoMap(t *field.Element) *edwards25519.Point {
+	r := new(field.Element).Square(t)
+	r.Multiply(r, feSqrtM1)
+	u := new(field.Element).Add(r, feOne)
+	u.Multiply(u, feOneMinusDSquare)
+	minusOne := new(field.Element).Negate(feOne)
+	// v = (-1 - r*D) * (r + D)
+	v := new(field.Element).Multiply(r, feD)
+	v.Subtract(minusOne, v)
+	v.Multiply(v, new(field.Element).Add(r, feD))
+
+	s, wasSquare := new(field.Element).SqrtRatio(u, v)
+	sPrime := new(field.Element).Multiply(s, t)
+	sPrime.Absolute(sPrime)
+	sPrime.Negate(sPrime)
+	s.Select(s, sPrime, wasSquare)
+	c := new(field.Element).Select(minusOne, r, wasSquare)
+
+	// N = c * (r - 1) * D_MINUS_ONE_SQ - v
+	N := new(field.Element).Subtract(r, feOne)
+	N.Multiply(N, c)
+	N.Multiply(N, feDMinusOneSquare)
+	N.Subtract(N, v)
+
+	sSquare := new(field.Element).Square(s)
+	w0 := new(field.Element).Multiply(s, v)
+	w0.Add(w0, w0)
+	w1 := new(field.Element).Multiply(N, feSqrtADMinusOne)
+	w2 := new(field.Element).Subtract(feOne, sSquare)
+	w3 := new(field.Element).Add(feOne, sSquare)
+
+	result, err := edwards25519.NewIdentityPoint().SetExtendedCoordinates(
+		new(field.Element).Multiply(w0, w3),
+		new(field.Element).Multiply(w2, w1),
+		new(field.Element).Multiply(w1, w3),
+		new(field.Element).Multiply(w0, w2),
+	)
+	if err != nil {
+		// unreachable: the map always lands on the curve
+		panic(err)
+	}
+	return result
+}
+
+// SetRistrettoUniformBytes maps 64 uniformly random bytes to a point, like RistrettoPoint::from_uniform_bytes.
+// The high bit of each 32-byte half is ignored.
+func (p *Point) SetRistrettoUniformBytes(b []byte) (*Point, error) {
+	if len(b) != 2*Ed25519KeySize {
+		return nil, fmt.Errorf("invalid ristretto255 uniform bytes length %d", len(b))
+	}
+	t1, _ := new(field.Element).SetBytes(b[:Ed25519KeySize])
+	t2, _ := new(field.Element).SetBytes(b[Ed25519KeySize:])
+	p.p.Add(ristrettoMap(t1), ristrettoMap(t2))
+	return p, nil
+}
+
+// IsRistrettoEqual compares two points as ristretto255 elements
+func IsRistrettoEqual(pA, pB *Point) bool {
+	return bytes.Equal(pA.RistrettoBytes(), pB.RistrettoBytes())
+}
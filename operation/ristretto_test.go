@@ -0,0 +1,46 @@
+package operation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestRistretto(t *testing.T) {
+	// multiples of the base point (RFC 9496, appendix A.1)
+	multiples := []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"e2f2ae0a6abc4e71a884a961c500515f58e30b6aa582dd8db6a65945e08d2d76",
+		"6a493210f7499cd17fecb510ae0cea23a110e8d5b901f8acadd3095c73a3b919",
+		"94741f5d5d52755ece4f23f044ee27d5d1ea1e2bd196b462166b16152a9d0259",
+	}
+	p := NewIdentityPoint()
+	for _, expected := range multiples {
+		Equal(t, expected, fmt.Sprintf("%x", p.RistrettoBytes()))
+		raw, _ := hex.DecodeString(expected)
+		decoded, err := new(Point).SetRistrettoBytes(raw)
+		Nil(t, err)
+		True(t, IsRistrettoEqual(decoded, p))
+		p.Add(p, NewGeneratorPoint())
+	}
+	// non-canonical and negative field elements are rejected
+	_, err := new(Point).SetRistrettoBytes(bytes.Repeat([]byte{0xff}, 32))
+	NotNil(t, err)
+	_, err = new(Point).SetRistrettoBytes(append([]byte{1}, make([]byte, 31)...))
+	NotNil(t, err)
+
+	// RistrettoPoint::from_uniform_bytes, computed with curve25519-dalek 4.1.3
+	uniform := make([]byte, 64)
+	for i := range uniform {
+		uniform[i] = byte(i)*37 + 11
+	}
+	mapped, err := new(Point).SetRistrettoUniformBytes(uniform)
+	Nil(t, err)
+	Equal(t, "500cb5716aa91e79c9edbff25b02d36ef4cc29b844a3445a91954cac793d0f38", fmt.Sprintf("%x", mapped.RistrettoBytes()))
+	mapped, err = new(Point).SetRistrettoUniformBytes(bytes.Repeat([]byte{0xff}, 64))
+	Nil(t, err)
+	Equal(t, "a64d86820abd393c6a5feef95b64945bc0c570adebae17a99882216945fbd37a", fmt.Sprintf("%x", mapped.RistrettoBytes()))
+}
@@ -3,12 +3,13 @@ package operation
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 	"sort"
 
 	"filippo.io/edwards25519"
-	operation_old "github.com/incognitochain/incognito-chain/privacy/operation"
 )
 
 type Scalar struct {
@@ -27,16 +28,42 @@ func (sc Scalar) String() string {
 	return fmt.Sprintf("%x", sc.ToBytesS())
 }
 
-func (sc Scalar) MarshalText() []byte {
-	return []byte(hex.EncodeToString(sc.ToBytesS()))
+// MarshalText encodes sc as the hex string of its 32-byte little-endian encoding
+func (sc Scalar) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(sc.ToBytesS())), nil
 }
 
-func (sc *Scalar) UnmarshalText(data []byte) (*Scalar, error) {
-	byteSlice, _ := hex.DecodeString(string(data))
-	if len(byteSlice) != Ed25519KeySize {
-		return nil, fmt.Errorf("invalid scalar byte size")
+// UnmarshalText decodes the output of MarshalText. Like FromBytesSStrict, it rejects non-canonical scalars.
+func (sc *Scalar) UnmarshalText(data []byte) error {
+	byteSlice, err := hex.DecodeString(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid scalar hex: %v", err)
 	}
-	return sc.FromBytesS(byteSlice), nil
+	return sc.UnmarshalBinary(byteSlice)
+}
+
+// MarshalBinary returns the 32-byte little-endian encoding of sc
+func (sc Scalar) MarshalBinary() ([]byte, error) {
+	return sc.ToBytesS(), nil
+}
+
+// UnmarshalBinary decodes a 32-byte encoding with FromBytesSStrict
+func (sc *Scalar) UnmarshalBinary(data []byte) error {
+	_, err := sc.FromBytesSStrict(data)
+	return err
+}
+
+// MarshalJSON encodes sc as a JSON string holding MarshalText's hex
+func (sc Scalar) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(sc.ToBytesS()))
+}
+
+func (sc *Scalar) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	return sc.UnmarshalText([]byte(str))
 }
 
 func (sc Scalar) ToBytesS() []byte {
@@ -50,6 +77,30 @@ func (sc *Scalar) FromBytesS(b []byte) *Scalar {
 	return sc
 }
 
+// FromBytesSStrict decodes a 32-byte little-endian scalar, rejecting encodings that are not reduced mod l.
+// Unlike FromBytesS, sc is left untouched on error. Use it on untrusted input.
+func (sc *Scalar) FromBytesSStrict(b []byte) (*Scalar, error) {
+	if len(b) != Ed25519KeySize {
+		return nil, fmt.Errorf("invalid scalar byte size")
+	}
+	if _, err := sc.s.SetCanonicalBytes(b); err != nil {
+		return nil, fmt.Errorf("non-canonical scalar encoding")
+	}
+	return sc, nil
+}
+
+// FromBytesWide sets sc to a 64-byte little-endian integer reduced mod l.
+// Use it to map uniformly random or hashed bytes to a scalar without bias.
+func (sc *Scalar) FromBytesWide(b []byte) (*Scalar, error) {
+	if len(b) != 2*Ed25519KeySize {
+		return nil, fmt.Errorf("invalid wide scalar byte size")
+	}
+	if _, err := sc.s.SetUniformBytes(b); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
 // func (sc *Scalar) SetKey(a *C25519.Key) (*Scalar, error) {
 // 	if sc == nil {
 // 		sc = new(Scalar)
@@ -66,17 +117,44 @@ func (sc *Scalar) Set(a *Scalar) *Scalar {
 	return sc
 }
 
+// RandomSource supplies the entropy for random scalars. Any io.Reader satisfies it; a nil source means crypto/rand.
+type RandomSource interface {
+	Read(p []byte) (n int, err error)
+}
+
+func randomSourceOrDefault(r RandomSource) RandomSource {
+	if r == nil {
+		return rand.Reader
+	}
+	return r
+}
+
+// RandomScalarFrom reads 64 bytes from r and reduces them to a uniformly random scalar
+func RandomScalarFrom(r RandomSource) (*Scalar, error) {
+	b := make([]byte, 2*Ed25519KeySize)
+	if _, err := io.ReadFull(randomSourceOrDefault(r), b); err != nil {
+		return nil, fmt.Errorf("cannot read random scalar: %v", err)
+	}
+	return NewScalar().FromBytesWide(b)
+}
+
+// RandomScalar returns a scalar drawn from crypto/rand. It panics if the system entropy source fails,
+// rather than handing out a predictable value; use RandomScalarFrom to get the error instead.
 func RandomScalar() *Scalar {
-	b := make([]byte, 64)
-	rand.Read(b)
-	res, _ := edwards25519.NewScalar().SetUniformBytes(b)
-	return &Scalar{*res}
+	res, err := RandomScalarFrom(nil)
+	if err != nil {
+		panic(err)
+	}
+	return res
 }
 
+// HashToScalar is the legacy hash-to-scalar: Keccak256(data) read as a little-endian integer mod l.
+// New transcripts should use HashToScalarWithDST.
 func HashToScalar(data []byte) *Scalar {
-	temp := operation_old.HashToScalar(data)
-	// h = Keccak256(h[:])
-	sc := (&Scalar{}).FromBytesS(temp.ToBytesS())
+	h := Keccak256(data)
+	var wide [2 * Ed25519KeySize]byte
+	copy(wide[:], h[:])
+	sc, _ := NewScalar().FromBytesWide(wide[:])
 	return sc
 }
 
@@ -134,8 +212,9 @@ func (sc *Scalar) MulAdd(a, b, c *Scalar) *Scalar {
 // 	return sc
 // }
 
+// ScalarValid always returns true: a Scalar is always reduced mod l. Canonical encodings are enforced when
+// decoding, by FromBytesSStrict. It is kept for callers of the old API.
 func (sc *Scalar) ScalarValid() bool {
-	// TODO
 	return true
 }
 
@@ -192,6 +271,33 @@ func (sc *Scalar) Invert(a *Scalar) *Scalar {
 	return sc
 }
 
+// BatchInvert replaces every scalar of scLst by its inverse, using Montgomery's trick: one inversion and 3(n-1)
+// multiplications instead of n inversions. The pointers in scLst must be distinct.
+// It returns an error and leaves scLst untouched if any input is zero.
+func BatchInvert(scLst []*Scalar) error {
+	if len(scLst) == 0 {
+		return nil
+	}
+	// prefix[i] = scLst[0] * ... * scLst[i-1]
+	prefix := make([]Scalar, len(scLst))
+	acc := NewScalar().FromUint64(1)
+	for i, sc := range scLst {
+		if IsScalarEqual(sc, ScZero) {
+			return fmt.Errorf("cannot invert zero scalar at index %d", i)
+		}
+		prefix[i].Set(acc)
+		acc.Mul(acc, sc)
+	}
+	acc.Invert(acc)
+	tmp := NewScalar()
+	for i := len(scLst) - 1; i >= 0; i-- {
+		tmp.Mul(acc, &prefix[i])
+		acc.Mul(acc, scLst[i])
+		scLst[i].Set(tmp)
+	}
+	return nil
+}
+
 func Reverse(x [32]byte) (result [32]byte) {
 	result = x
 	// A key is in little-endian, but the big package wants the bytes in
@@ -0,0 +1,43 @@
+package operation
+
+import (
+	"math/big"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestFromBytesSStrictRejectsNonCanonical(t *testing.T) {
+	sc := RandomScalar()
+	_, err := new(Scalar).FromBytesSStrict(sc.ToBytesS())
+	Nil(t, err)
+
+	// sc + l encodes the same residue non-canonically
+	l, _ := new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+	var le [32]byte
+	copy(le[:], sc.ToBytesS())
+	be := Reverse(le)
+	var shiftedBE [32]byte
+	new(big.Int).Add(new(big.Int).SetBytes(be[:]), l).FillBytes(shiftedBE[:])
+	shiftedLE := Reverse(shiftedBE)
+	_, err = new(Scalar).FromBytesSStrict(shiftedLE[:])
+	NotNil(t, err)
+}
+
+func TestBatchInvert(t *testing.T) {
+	scalars := []*Scalar{RandomScalar(), ScOne, RandomScalar(), ScMinusOne}
+	inverses := make([]*Scalar, len(scalars))
+	for i := range scalars {
+		inverses[i] = new(Scalar).Set(scalars[i])
+	}
+	NoError(t, BatchInvert(inverses))
+	for i := range scalars {
+		True(t, IsScalarEqual(inverses[i], new(Scalar).Invert(scalars[i])))
+	}
+	NoError(t, BatchInvert(nil))
+
+	withZero := []*Scalar{RandomScalar(), NewScalar().FromUint64(0)}
+	before := new(Scalar).Set(withZero[0])
+	Error(t, BatchInvert(withZero))
+	True(t, IsScalarEqual(before, withZero[0]))
+}
@@ -0,0 +1,212 @@
+package operation
+
+import (
+	"fmt"
+	"io"
+)
+
+// ScalarVector is a contiguous vector of scalars. Its methods write into the receiver and return it, so
+// vectors can be reused as scratch space; the receiver may alias any of the operands.
+// All operands must have the same length as the receiver.
+type ScalarVector []Scalar
+
+// PointVector is a contiguous vector of points, with the same conventions as ScalarVector
+type PointVector []Point
+
+func NewScalarVector(n int) ScalarVector {
+	return make(ScalarVector, n)
+}
+
+// ScalarVectorFromSlice copies a list of scalars into a new vector
+func ScalarVectorFromSlice(scLst []*Scalar) ScalarVector {
+	v := make(ScalarVector, len(scLst))
+	for i := range scLst {
+		v[i].Set(scLst[i])
+	}
+	return v
+}
+
+func checkVectorLength(n int, lengths ...int) {
+	for _, l := range lengths {
+		if l != n {
+			panic("Cannot operate on vectors of different size")
+		}
+	}
+}
+
+// Ptrs returns pointers to the elements of v, for APIs that take []*Scalar. The elements are shared, not copied.
+func (v ScalarVector) Ptrs() []*Scalar {
+	result := make([]*Scalar, len(v))
+	for i := range v {
+		result[i] = &v[i]
+	}
+	return result
+}
+
+func (v ScalarVector) Set(a ScalarVector) ScalarVector {
+	checkVectorLength(len(v), len(a))
+	copy(v, a)
+	return v
+}
+
+func (v ScalarVector) Clone() ScalarVector {
+	return append(ScalarVector{}, v...)
+}
+
+// RandomFrom fills v with uniformly random scalars, reading from r once for the whole vector. A nil r means crypto/rand.
+func (v ScalarVector) RandomFrom(r RandomSource) (ScalarVector, error) {
+	b := make([]byte, 2*Ed25519KeySize*len(v))
+	if _, err := io.ReadFull(randomSourceOrDefault(r), b); err != nil {
+		return nil, fmt.Errorf("cannot read random vector: %v", err)
+	}
+	for i := range v {
+		v[i].FromBytesWide(b[2*Ed25519KeySize*i : 2*Ed25519KeySize*(i+1)])
+	}
+	return v, nil
+}
+
+// Random fills v from crypto/rand, panicking like RandomScalar if the entropy source fails
+func (v ScalarVector) Random() ScalarVector {
+	if _, err := v.RandomFrom(nil); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Fill sets every element of v to s
+func (v ScalarVector) Fill(s *Scalar) ScalarVector {
+	for i := range v {
+		v[i].Set(s)
+	}
+	return v
+}
+
+func (v ScalarVector) Add(a, b ScalarVector) ScalarVector {
+	checkVectorLength(len(v), len(a), len(b))
+	for i := range v {
+		v[i].Add(&a[i], &b[i])
+	}
+	return v
+}
+
+func (v ScalarVector) Sub(a, b ScalarVector) ScalarVector {
+	checkVectorLength(len(v), len(a), len(b))
+	for i := range v {
+		v[i].Sub(&a[i], &b[i])
+	}
+	return v
+}
+
+// Hadamard sets v to the element-wise product of a and b
+func (v ScalarVector) Hadamard(a, b ScalarVector) ScalarVector {
+	checkVectorLength(len(v), len(a), len(b))
+	for i := range v {
+		v[i].Mul(&a[i], &b[i])
+	}
+	return v
+}
+
+// AddScalar sets v[i] = a[i] + s
+func (v ScalarVector) AddScalar(a ScalarVector, s *Scalar) ScalarVector {
+	checkVectorLength(len(v), len(a))
+	for i := range v {
+		v[i].Add(&a[i], s)
+	}
+	return v
+}
+
+// MulScalar sets v[i] = a[i] * s
+func (v ScalarVector) MulScalar(a ScalarVector, s *Scalar) ScalarVector {
+	checkVectorLength(len(v), len(a))
+	for i := range v {
+		v[i].Mul(&a[i], s)
+	}
+	return v
+}
+
+// MulScalarAdd sets v[i] = a[i] * s + b[i]
+func (v ScalarVector) MulScalarAdd(a ScalarVector, s *Scalar, b ScalarVector) ScalarVector {
+	checkVectorLength(len(v), len(a), len(b))
+	for i := range v {
+		v[i].MulAdd(&a[i], s, &b[i])
+	}
+	return v
+}
+
+// Fold sets v[i] = lo[i] * x + hi[i] * y, the halving step of the inner product argument
+func (v ScalarVector) Fold(lo, hi ScalarVector, x, y *Scalar) ScalarVector {
+	checkVectorLength(len(v), len(lo), len(hi))
+	var tmp Scalar
+	for i := range v {
+		tmp.Mul(&hi[i], y)
+		v[i].MulAdd(&lo[i], x, &tmp)
+	}
+	return v
+}
+
+// Powers sets v[i] = base^i
+func (v ScalarVector) Powers(base *Scalar) ScalarVector {
+	if len(v) == 0 {
+		return v
+	}
+	v[0].FromUint64(1)
+	for i := 1; i < len(v); i++ {
+		v[i].Mul(&v[i-1], base)
+	}
+	return v
+}
+
+// InnerProduct returns <v, b>
+func (v ScalarVector) InnerProduct(b ScalarVector) *Scalar {
+	checkVectorLength(len(v), len(b))
+	result := new(Scalar).FromUint64(0)
+	for i := range v {
+		result.MulAdd(&v[i], &b[i], result)
+	}
+	return result
+}
+
+func NewPointVector(n int) PointVector {
+	return make(PointVector, n)
+}
+
+// PointVectorFromSlice copies a list of points into a new vector
+func PointVectorFromSlice(pLst []*Point) PointVector {
+	v := make(PointVector, len(pLst))
+	for i := range pLst {
+		v[i].Set(pLst[i])
+	}
+	return v
+}
+
+// Ptrs returns pointers to the elements of v, for APIs that take []*Point. The elements are shared, not copied.
+func (v PointVector) Ptrs() []*Point {
+	result := make([]*Point, len(v))
+	for i := range v {
+		result[i] = &v[i]
+	}
+	return result
+}
+
+func (v PointVector) Clone() PointVector {
+	return append(PointVector{}, v...)
+}
+
+// ScalarMult sets v[i] = s[i] * a[i]
+func (v PointVector) ScalarMult(a PointVector, s ScalarVector) PointVector {
+	checkVectorLength(len(v), len(a), len(s))
+	for i := range v {
+		v[i].ScalarMult(&a[i], &s[i])
+	}
+	return v
+}
+
+// Fold sets v[i] = x * lo[i] + y * hi[i], the halving step of the inner product argument
+func (v PointVector) Fold(lo, hi PointVector, x, y *Scalar) PointVector {
+	checkVectorLength(len(v), len(lo), len(hi))
+	scalars := []*Scalar{x, y}
+	for i := range v {
+		v[i].MultiScalarMult(scalars, []*Point{&lo[i], &hi[i]})
+	}
+	return v
+}
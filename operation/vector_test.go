@@ -0,0 +1,47 @@
+package operation
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestScalarVector(t *testing.T) {
+	n := 8
+	a := NewScalarVector(n).Random()
+	b := NewScalarVector(n).Random()
+	x := RandomScalar()
+
+	expected := new(Scalar).FromUint64(0)
+	for i := range a {
+		expected.Add(expected, new(Scalar).Mul(&a[i], &b[i]))
+	}
+	True(t, IsScalarEqual(expected, a.InnerProduct(b)))
+
+	// in-place ops must match element-wise results computed from copies
+	c := a.Clone()
+	c.MulScalarAdd(b, x, c)
+	for i := range c {
+		True(t, IsScalarEqual(&c[i], new(Scalar).MulAdd(&b[i], x, &a[i])))
+	}
+	folded := a.Clone()
+	folded = folded[:n/2].Fold(folded[:n/2], folded[n/2:], x, ScOne)
+	for i := range folded {
+		True(t, IsScalarEqual(&folded[i], new(Scalar).MulAdd(&a[i], x, &a[i+n/2])))
+	}
+	powers := NewScalarVector(n).Powers(x)
+	True(t, IsScalarEqual(&powers[0], ScOne))
+	True(t, IsScalarEqual(&powers[3], new(Scalar).Mul(x, new(Scalar).Mul(x, x))))
+	Panics(t, func() { a.Add(a, b[1:]) })
+
+	points := make([]*Point, n)
+	for i := range points {
+		points[i] = RandomPoint()
+	}
+	G := PointVectorFromSlice(points)
+	y := RandomScalar()
+	G = G[:n/2].Fold(G[:n/2], G[n/2:], x, y)
+	for i := range G {
+		True(t, IsPointEqual(&G[i], new(Point).AddPedersen(x, points[i], y, points[i+n/2])))
+	}
+}
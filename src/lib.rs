@@ -39,6 +39,22 @@ fn bulletproofs_prove_multiple(witness: &repr_c::Vec<u64>, blindings: &repr_c::V
 pub extern fn naive_verify() {
 }
 
+// writes the proof checked by TestDalekGoldenProof in bulletproofs/bulletproofs_test.go
+#[test]
+fn golden_prove_multiple() -> ::std::io::Result<()> {
+    let values: Vec<u64> = vec![1, 1 << 40, 0, u64::MAX];
+    let blindings: Vec<Scalar> = (1001..1005u64).map(Scalar::from).collect();
+    let pc_gens = PedersenGens::default();
+    let bp_gens = BulletproofGens::new(64, values.len());
+
+    let mut transcript = Transcript::new(b"");
+    let (proof, _) = RangeProof::prove_multiple(&bp_gens, &pc_gens, &mut transcript, &values, &blindings, 64)
+        .expect("golden inputs are in range");
+    let encoded: String = proof.to_bytes().iter().map(|b| format!("{:02x}", b)).collect();
+    ::std::fs::create_dir_all("bulletproofs/testdata")?;
+    ::std::fs::write("bulletproofs/testdata/dalek_prove_multiple.hex", encoded + "\n")
+}
+
 #[::safer_ffi::cfg_headers]
 #[test]
 fn generate_headers() -> ::std::io::Result<()> {